@@ -0,0 +1,181 @@
+package api
+
+import (
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/repository"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type principalKey struct{}
+
+// authenticated - Only run handler for requests carrying a token that grants scope.
+// Tokens are read from Bearer or token authorizations, or from Basic ones as git clients send them.
+func authenticated(scope auth.Scope, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		if principal == nil {
+			challenge(w)
+			return
+		}
+		if !principal.Can(scope) {
+			http.Error(w, "token lacks the "+string(scope)+" scope", http.StatusForbidden)
+			return
+		}
+
+		handler(w, withPrincipal(r, principal))
+	}
+}
+
+// anonymous - Run handler with or without a token, handlers filtering what anonymous requests see
+func anonymous(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		handler(w, withPrincipal(r, principal))
+	}
+}
+
+// authorized - Only run handler for requests on a repository whose caller holds role on it with a token
+// granting scope. Anonymous requests may read public repositories and the repositories the caller cannot
+// see are reported as not found, so that their existence does not leak.
+func authorized(scope auth.Scope, role repository.Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		granted, err := auth.RoleFor(principal, mux.Vars(r)["repository"])
+		if errors.Is(err, repository.NotFoundError) && principal == nil {
+			// anonymous requests cannot tell missing repositories from private ones either
+			challenge(w)
+			return
+		}
+		if err != nil {
+			handleError(err, w)
+			return
+		}
+
+		// anonymous requests hold no token, reading being all they may do
+		scoped := principal.Can(scope) || (principal == nil && scope == auth.RepoRead)
+
+		switch {
+		case granted.Includes(role) && scoped:
+			handler(w, withPrincipal(r, principal))
+		case principal == nil:
+			// lets git clients prompt for credentials
+			challenge(w)
+		case !granted.Includes(repository.RoleRead):
+			handleError(repository.NotFoundError, w)
+		case !principal.Can(scope):
+			http.Error(w, "token lacks the "+string(scope)+" scope", http.StatusForbidden)
+		default:
+			http.Error(w, "the "+string(role)+" role is required", http.StatusForbidden)
+		}
+	}
+}
+
+// authorizedService - Like authorized, reading or writing depending on the git service requested
+func authorizedService(handler http.HandlerFunc) http.HandlerFunc {
+	read := authorized(auth.RepoRead, repository.RoleRead, handler)
+	write := authorized(auth.RepoWrite, repository.RoleWrite, handler)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if repository.Service(r.URL.Query().Get("service")) == repository.ReceivePack {
+			write(w, r)
+		} else {
+			read(w, r)
+		}
+	}
+}
+
+// authenticate - Principal of a request, nil without a token. Invalid tokens are rejected.
+func authenticate(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, true
+	}
+
+	principal, err := auth.Authenticate(token)
+	if errors.Is(err, auth.UnauthorizedError) {
+		challenge(w)
+		return nil, false
+	}
+	if err != nil {
+		handleError(err, w)
+		return nil, false
+	}
+	return principal, true
+}
+
+func challenge(w http.ResponseWriter) {
+	w.Header().Add("WWW-Authenticate", `Basic realm="gituim"`)
+	w.Header().Add("WWW-Authenticate", `Bearer realm="gituim"`)
+	http.Error(w, auth.UnauthorizedError.Error(), http.StatusUnauthorized)
+}
+
+func withPrincipal(r *http.Request, principal *auth.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+}
+
+// getPrincipal - Principal of a request, nil when it is anonymous
+func getPrincipal(r *http.Request) *auth.Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*auth.Principal)
+	return principal
+}
+
+// requestToken - Raw token of a request, empty when there is none
+func requestToken(r *http.Request) string {
+	if username, password, ok := r.BasicAuth(); ok {
+		// the token can be given as password with any username, or as username alone
+		if password != "" {
+			return password
+		}
+		return username
+	}
+
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "token") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// searchableRepositories - Repositories the principal can read among the requested ones, every one they can
+// read when none are requested
+func searchableRepositories(principal *auth.Principal, requested []string) ([]string, error) {
+	names := requested
+	if len(names) == 0 {
+		var err error
+		if names, err = repository.ListRepositories(); err != nil {
+			return nil, err
+		}
+	}
+	return auth.VisibleRepositories(principal, names)
+}
+
+// getPusher - Who a request updates references as, checked against protected branches
+func getPusher(r *http.Request) (*repository.Pusher, error) {
+	principal := getPrincipal(r)
+	if principal == nil || principal.User == "" {
+		return &repository.Pusher{}, nil
+	}
+
+	teams, err := auth.TeamsOf(principal.User)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Pusher{User: principal.User, Teams: teams}, nil
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"com/gitlab/gituim/auth"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestAuthenticated(t *testing.T) {
+	reader := createTestUser(t, "authn-reader", auth.RepoRead)
+	writer := createTestUser(t, "authn-writer", auth.RepoWrite)
+	administrator := createTestUser(t, "authn-admin", auth.Admin)
+
+	tests := []struct {
+		name     string
+		target   string
+		token    string
+		expected int
+	}{
+		{"anonymous", "/tokens", "", http.StatusUnauthorized},
+		{"unknown token", "/tokens", "gituim_unknown", http.StatusUnauthorized},
+		{"read scope", "/tokens", reader, http.StatusOK},
+		{"write scope grants read", "/tokens", writer, http.StatusOK},
+		{"anonymous admin route", "/users", "", http.StatusUnauthorized},
+		{"read scope on admin route", "/users", reader, http.StatusForbidden},
+		{"write scope on admin route", "/users", writer, http.StatusForbidden},
+		{"admin scope", "/users", administrator, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(http.MethodGet, test.target, test.token, nil)
+		if w.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.expected, w.Code, w.Body)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a challenge", test.name)
+		}
+	}
+}
+
+func TestAuthenticatedTokenSchemes(t *testing.T) {
+	token := createTestUser(t, "authn-schemes", auth.RepoRead)
+
+	requests := map[string]func(r *http.Request){
+		"bearer":         func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) },
+		"token":          func(r *http.Request) { r.Header.Set("Authorization", "token "+token) },
+		"basic password": func(r *http.Request) { r.SetBasicAuth("git", token) },
+		"basic username": func(r *http.Request) { r.SetBasicAuth(token, "") },
+	}
+
+	for name, authorize := range requests {
+		r := httptest.NewRequest(http.MethodGet, "/tokens", nil)
+		authorize(r)
+
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	previous := auth.GAdminToken
+	auth.GAdminToken = "gituim_bootstrap"
+	t.Cleanup(func() { auth.GAdminToken = previous })
+
+	if w := serveRequest(http.MethodGet, "/users", "gituim_bootstrap", nil); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	owner := createTestUser(t, "authz-owner", auth.RepoWrite)
+	reader := createTestUser(t, "authz-reader", auth.RepoWrite)
+	outsider := createTestUser(t, "authz-outsider", auth.RepoWrite)
+	narrow := createTestUser(t, "authz-narrow", auth.RepoRead)
+	member := createTestUser(t, "authz-member", auth.RepoWrite)
+	administrator := createTestUser(t, "authz-admin", auth.Admin)
+
+	for _, name := range []string{"authz-public", "authz-private"} {
+		if w := serveRequest(http.MethodPost, "/repositories", owner, &RepositoryModel{Name: name}); w.Code != http.StatusOK {
+			t.Fatalf("create %s: expected 200, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+
+	if _, err := auth.CreateTeam("authz-team"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AddTeamMember("authz-team", "authz-member"); err != nil {
+		t.Fatal(err)
+	}
+
+	grants := []struct {
+		target string
+		body   interface{}
+	}{
+		{"/repositories/authz-public/access", &AccessModel{Visibility: "public"}},
+		{"/repositories/authz-private/access/users/authz-reader", &RoleModel{Role: "read"}},
+		{"/repositories/authz-private/access/users/authz-narrow", &RoleModel{Role: "admin"}},
+		{"/repositories/authz-private/access/teams/authz-team", &RoleModel{Role: "write"}},
+	}
+	for _, grant := range grants {
+		method := http.MethodPut
+		if _, ok := grant.body.(*AccessModel); ok {
+			method = http.MethodPatch
+		}
+		if w := serveRequest(method, grant.target, owner, grant.body); w.Code != http.StatusOK {
+			t.Fatalf("grant %s: expected 200, got %d: %s", grant.target, w.Code, w.Body)
+		}
+	}
+
+	description := "updated"
+	update := &UpdateRepositoryModel{Description: &description}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		token    string
+		body     interface{}
+		expected int
+	}{
+		{"anonymous reads public", http.MethodGet, "/repositories/authz-public", "", nil, http.StatusOK},
+		{"anonymous reads private", http.MethodGet, "/repositories/authz-private", "", nil, http.StatusUnauthorized},
+		{"anonymous reads missing", http.MethodGet, "/repositories/authz-missing", "", nil, http.StatusUnauthorized},
+		{"anonymous writes public", http.MethodPatch, "/repositories/authz-public", "", update, http.StatusUnauthorized},
+		{"outsider reads public", http.MethodGet, "/repositories/authz-public", outsider, nil, http.StatusOK},
+		{"outsider reads private", http.MethodGet, "/repositories/authz-private", outsider, nil, http.StatusNotFound},
+		{"outsider reads missing", http.MethodGet, "/repositories/authz-missing", outsider, nil, http.StatusNotFound},
+		{"outsider writes public", http.MethodPatch, "/repositories/authz-public", outsider, update, http.StatusForbidden},
+		{"reader reads private", http.MethodGet, "/repositories/authz-private", reader, nil, http.StatusOK},
+		{"reader role too low", http.MethodPatch, "/repositories/authz-private", reader, update, http.StatusForbidden},
+		{"reader role too low for access", http.MethodGet, "/repositories/authz-private/access", reader, nil, http.StatusForbidden},
+		{"narrow scope reads", http.MethodGet, "/repositories/authz-private", narrow, nil, http.StatusOK},
+		{"narrow scope too narrow", http.MethodPatch, "/repositories/authz-private", narrow, update, http.StatusForbidden},
+		{"team member reads", http.MethodGet, "/repositories/authz-private", member, nil, http.StatusOK},
+		{"team member role too low", http.MethodPatch, "/repositories/authz-private", member, update, http.StatusForbidden},
+		{"owner administers", http.MethodPatch, "/repositories/authz-private", owner, update, http.StatusOK},
+		{"admin scope reads private", http.MethodGet, "/repositories/authz-private", administrator, nil, http.StatusOK},
+		{"admin scope administers", http.MethodPatch, "/repositories/authz-private", administrator, update, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(test.method, test.target, test.token, test.body)
+		if w.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.expected, w.Code, w.Body)
+		}
+	}
+}
+
+func TestListRepositoriesVisibility(t *testing.T) {
+	owner := createTestUser(t, "visible-owner", auth.RepoWrite)
+	for _, name := range []string{"visible-public", "visible-private"} {
+		if w := serveRequest(http.MethodPost, "/repositories", owner, &RepositoryModel{Name: name}); w.Code != http.StatusOK {
+			t.Fatalf("create %s: expected 200, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	w := serveRequest(http.MethodPatch, "/repositories/visible-public/access", owner, &AccessModel{Visibility: "public"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		token    string
+		expected map[string]bool
+	}{
+		{"", map[string]bool{"visible-public": true, "visible-private": false}},
+		{owner, map[string]bool{"visible-public": true, "visible-private": true}},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(http.MethodGet, "/repositories", test.token, nil)
+
+		var list RepositoryListModel
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("unable to decode %s: %v", w.Body, err)
+		}
+		for name, expected := range test.expected {
+			if slices.Contains(list.Repositories, name) != expected {
+				t.Errorf("token %q: expected %s listed to be %v, got %v", test.token, name, expected, list.Repositories)
+			}
+		}
+	}
+}
+
+func TestNamespaceAuthorization(t *testing.T) {
+	administrator := createTestUser(t, "ns-admin", auth.Admin)
+	member := createTestUser(t, "ns-member", auth.RepoWrite)
+	outsider := createTestUser(t, "ns-outsider", auth.RepoWrite)
+
+	for _, name := range []string{"ns-public", "ns-private", "ns-private/nested", "ns-empty"} {
+		if w := serveRequest(http.MethodPost, "/namespaces", administrator, &NamespaceModel{Name: name}); w.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected 201, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	for _, name := range []string{"ns-public/repo", "ns-private/nested/repo"} {
+		if w := serveRequest(http.MethodPost, "/repositories", administrator, &RepositoryModel{Name: name}); w.Code != http.StatusOK {
+			t.Fatalf("create %s: expected 200, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	grants := []struct {
+		method string
+		target string
+		body   interface{}
+	}{
+		{http.MethodPatch, "/repositories/ns-public/repo/access", &AccessModel{Visibility: "public"}},
+		{http.MethodPut, "/repositories/ns-private/nested/repo/access/users/ns-member", &RoleModel{Role: "read"}},
+	}
+	for _, grant := range grants {
+		if w := serveRequest(grant.method, grant.target, administrator, grant.body); w.Code != http.StatusOK {
+			t.Fatalf("grant %s: expected 200, got %d: %s", grant.target, w.Code, w.Body)
+		}
+	}
+
+	listings := []struct {
+		name     string
+		token    string
+		expected map[string]bool
+	}{
+		{"anonymous", "", map[string]bool{"ns-public": true, "ns-private": false, "ns-private/nested": false, "ns-empty": false}},
+		{"outsider", outsider, map[string]bool{"ns-public": true, "ns-private": false, "ns-empty": false}},
+		{"member", member, map[string]bool{"ns-public": true, "ns-private": true, "ns-private/nested": true, "ns-empty": false}},
+		{"admin", administrator, map[string]bool{"ns-public": true, "ns-private": true, "ns-empty": true}},
+	}
+	for _, test := range listings {
+		w := serveRequest(http.MethodGet, "/namespaces", test.token, nil)
+
+		var list NamespaceListModel
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("%s: unable to decode %s: %v", test.name, w.Body, err)
+		}
+		for name, expected := range test.expected {
+			if slices.Contains(list.Namespaces, name) != expected {
+				t.Errorf("%s: expected %s listed to be %v, got %v", test.name, name, expected, list.Namespaces)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		token    string
+		body     interface{}
+		expected int
+	}{
+		{"anonymous reads public namespace", http.MethodGet, "/namespaces/ns-public", "", nil, http.StatusOK},
+		{"anonymous reads private namespace", http.MethodGet, "/namespaces/ns-private", "", nil, http.StatusUnauthorized},
+		{"anonymous reads missing namespace", http.MethodGet, "/namespaces/ns-missing", "", nil, http.StatusUnauthorized},
+		{"outsider reads private namespace", http.MethodGet, "/namespaces/ns-private", outsider, nil, http.StatusNotFound},
+		{"outsider reads empty namespace", http.MethodGet, "/namespaces/ns-empty", outsider, nil, http.StatusNotFound},
+		{"member reads private namespace", http.MethodGet, "/namespaces/ns-private", member, nil, http.StatusOK},
+		{"admin reads empty namespace", http.MethodGet, "/namespaces/ns-empty", administrator, nil, http.StatusOK},
+		{"outsider creates in namespace", http.MethodPost, "/repositories", outsider, &RepositoryModel{Name: "ns-public/taken"}, http.StatusForbidden},
+		{"outsider creates at the root", http.MethodPost, "/repositories", outsider, &RepositoryModel{Name: "ns-own"}, http.StatusOK},
+		{"outsider moves into namespace", http.MethodPost, "/repositories/ns-own/move", outsider, &MoveRepositoryModel{Namespace: "ns-public"}, http.StatusForbidden},
+		{"admin creates in namespace", http.MethodPost, "/repositories", administrator, &RepositoryModel{Name: "ns-empty/repo"}, http.StatusOK},
+		{"admin moves into namespace", http.MethodPost, "/repositories/ns-own/move", administrator, &MoveRepositoryModel{Namespace: "ns-public"}, http.StatusOK},
+		{"outsider moves back to the root", http.MethodPost, "/repositories/ns-public/ns-own/move", outsider, &MoveRepositoryModel{}, http.StatusOK},
+	}
+	for _, test := range tests {
+		w := serveRequest(test.method, test.target, test.token, test.body)
+		if w.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.expected, w.Code, w.Body)
+		}
+	}
+}
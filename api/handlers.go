@@ -1,20 +1,38 @@
 package api
 
 import (
+	"bufio"
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/index"
 	"com/gitlab/gituim/repository"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
 	"io"
+	"log"
+	"mime"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 )
 
-func ListRepositoriesHandler(w http.ResponseWriter, _ *http.Request) {
-	repos, err := repository.ListRepositories()
+func ListRepositoriesHandler(w http.ResponseWriter, r *http.Request) {
+	repos, err := repository.ListNamespaceRepositories(r.URL.Query().Get("namespace"))
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 
-	if repos != nil {
+	repos, err = auth.VisibleRepositories(getPrincipal(r), repos)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	if len(repos) > 0 {
 		data, err := json.Marshal(RepositoryListModel{Repositories: repos})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -46,7 +64,21 @@ func CreateRepositoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = repository.CreateRepository(repo.Name)
+	// namespaces belong to no one, only administrators put repositories in them
+	principal := getPrincipal(r)
+	if strings.Contains(repo.Name, "/") && !principal.Can(auth.Admin) {
+		http.Error(w, "creating a repository in a namespace requires the "+string(auth.Admin)+" scope", http.StatusForbidden)
+		return
+	}
+
+	// repositories are private, only their creator administers them at first. Tokens of no user, like
+	// GITUIM_ADMIN_TOKEN, leave them to administrators until roles are granted.
+	var admin string
+	if principal != nil {
+		admin = principal.User
+	}
+
+	_, err = repository.CreateRepository(repo.Name, admin)
 	if err != nil {
 		handleError(err, w)
 		return
@@ -68,9 +100,7 @@ func GetRepositoryInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := json.Marshal(RepositoryModel{
-		Name:   info.Repository,
-		IsBare: info.IsBare})
+	data, err := json.Marshal(buildRepositoryModel(info))
 	if err != nil {
 		handleError(err, w)
 		return
@@ -84,6 +114,50 @@ func GetRepositoryInfoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func UpdateRepositoryHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request UpdateRepositoryModel
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		http.Error(w, "invalid repository update", http.StatusBadRequest)
+		return
+	}
+
+	info, err := repository.UpdateRepository(repositoryName, repository.RepositoryUpdate{
+		Description:   request.Description,
+		DefaultBranch: request.DefaultBranch,
+		Topics:        request.Topics,
+		Properties:    request.Properties,
+	})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildRepositoryModel(info))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func DeleteRepositoryHandler(w http.ResponseWriter, r *http.Request) {
 	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
@@ -118,12 +192,7 @@ func ListBranchesHandler(w http.ResponseWriter, r *http.Request) {
 	if branches != nil {
 		dto := BranchListModel{}
 		for _, branch := range branches {
-			dto.Branches = append(dto.Branches, &BranchModel{
-				Branch: branch.Branch,
-				Commit: branch.Commit.String(),
-				Tree:   branch.Tree.String(),
-				Parent: branch.Parent.String(),
-			})
+			dto.Branches = append(dto.Branches, buildBranchModel(&branch))
 		}
 
 		data, err := json.Marshal(dto)
@@ -160,11 +229,7 @@ func GetBranchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if branch != nil {
-		data, err := json.Marshal(BranchModel{
-			Branch: branchName,
-			Commit: branch.Commit.String(),
-			Tree:   branch.Tree.String(),
-			Parent: branch.Parent.String()})
+		data, err := json.Marshal(buildBranchModel(branch))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -180,24 +245,95 @@ func GetBranchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetCommitHandler(w http.ResponseWriter, r *http.Request) {
+func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
 	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
 		return
 	}
 
-	commitOid, ok := getVar(w, r, "commit")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request CreateBranchModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Branch == "" || request.Revision == "" {
+		http.Error(w, "invalid branch request", http.StatusBadRequest)
+		return
+	}
+
+	pusher, err := getPusher(r)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	branch, err := repository.CreateBranch(repositoryName, request.Branch, request.Revision, pusher)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildBranchModel(branch))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", request.Branch)
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateBranchHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
 		return
 	}
 
-	commit, err := repository.LookupCommit(repositoryName, commitOid)
+	branchName, ok := getVar(w, r, "branch")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request UpdateBranchModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || (request.Name == "" && request.Revision == "") {
+		http.Error(w, "invalid branch update", http.StatusBadRequest)
+		return
+	}
+
+	pusher, err := getPusher(r)
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 
-	data, err := json.Marshal(buildCommitModel(commit))
+	branch, err := repository.GetBranch(repositoryName, branchName)
+	if request.Revision != "" {
+		branch, err = repository.UpdateBranch(repositoryName, branchName, request.Revision, request.Expected, request.Force, pusher)
+	}
+	if err == nil && request.Name != "" && request.Name != branchName {
+		branch, err = repository.RenameBranch(repositoryName, branchName, request.Name, pusher)
+	}
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildBranchModel(branch))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -211,30 +347,122 @@ func GetCommitHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetTreeHandler(w http.ResponseWriter, r *http.Request) {
+func DeleteBranchHandler(w http.ResponseWriter, r *http.Request) {
 	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
 		return
 	}
 
-	treeOid, ok := getVar(w, r, "tree")
+	branchName, ok := getVar(w, r, "branch")
 	if !ok {
 		return
 	}
 
-	tree, err := repository.LookupTree(repositoryName, treeOid)
+	pusher, err := getPusher(r)
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 
-	data, err := json.Marshal(buildTreeModel(tree))
+	err = repository.DeleteBranch(repositoryName, branchName, r.URL.Query().Get("expected"), pusher)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func CreateCommitHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request CreateCommitModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Branch == "" || request.Message == "" || request.Author == nil {
+		http.Error(w, "invalid commit request", http.StatusBadRequest)
+		return
+	}
+
+	author, err := parseSignature(request.Author)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	committer, err := parseSignature(request.Committer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pusher, err := getPusher(r)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	options := repository.CommitOptions{
+		Branch:    request.Branch,
+		Parent:    request.Parent,
+		Author:    author,
+		Committer: committer,
+		Message:   request.Message,
+		Pusher:    pusher,
+	}
+	for _, action := range request.Actions {
+		change := repository.FileChange{
+			Action:       repository.FileAction(action.Action),
+			Path:         action.Path,
+			PreviousPath: action.PreviousPath,
+			Executable:   action.Executable,
+		}
+
+		var content []byte
+		switch action.Encoding {
+		case "base64":
+			content, err = base64.StdEncoding.DecodeString(action.Content)
+			if err != nil {
+				http.Error(w, "invalid base64 content for "+action.Path, http.StatusBadRequest)
+				return
+			}
+		case "", "text":
+			content = []byte(action.Content)
+		default:
+			http.Error(w, "unknown encoding "+action.Encoding, http.StatusBadRequest)
+			return
+		}
+
+		// moves and chmods keep the existing content unless some is given
+		if action.Content != "" || change.Action == repository.FileCreate || change.Action == repository.FileUpdate {
+			change.Content = content
+		}
+
+		options.Changes = append(options.Changes, change)
+	}
+
+	commit, err := repository.CreateCommit(repositoryName, options)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildCommitModel(commit))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Add("Location", commit.Commit.String())
+	w.WriteHeader(http.StatusCreated)
 	_, err = w.Write(data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -242,24 +470,24 @@ func GetTreeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetBlobHandler(w http.ResponseWriter, r *http.Request) {
+func GetCommitHandler(w http.ResponseWriter, r *http.Request) {
 	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
 		return
 	}
 
-	blobOid, ok := getVar(w, r, "blob")
+	commitOid, ok := getVar(w, r, "commit")
 	if !ok {
 		return
 	}
 
-	blob, err := repository.LookupBlob(repositoryName, blobOid)
+	commit, err := repository.LookupCommit(repositoryName, commitOid)
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 
-	data, err := json.Marshal(buildBlobModel(blob))
+	data, err := json.Marshal(buildCommitModel(commit))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -273,20 +501,57 @@ func GetBlobHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func ListTagsHandler(w http.ResponseWriter, r *http.Request) {
+func ListCommitsHandler(w http.ResponseWriter, r *http.Request) {
 	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
 		return
 	}
 
-	tags, err := repository.ListRepositoryTags(repositoryName)
+	options, ok := getLogOptions(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := repository.ListCommits(repositoryName, options)
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 
-	if len(tags) > 0 {
-		data, err := json.Marshal(TagListModel{Tags: tags})
+	writeCommitLog(w, history)
+}
+
+func SearchCommitsHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	options, ok := getLogOptions(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := repository.SearchCommits(repositoryName, options)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeCommitLog(w, history)
+}
+
+// writeCommitLog - Write a page of commits, no content when it is empty
+func writeCommitLog(w http.ResponseWriter, history *repository.CommitLog) {
+	if len(history.Commits) > 0 {
+		dto := CommitListModel{Next: history.Next}
+		for _, entry := range history.Commits {
+			model := buildCommitModel(entry.Commit)
+			model.Path = entry.Path
+			dto.Commits = append(dto.Commits, model)
+		}
+
+		data, err := json.Marshal(dto)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -303,24 +568,80 @@ func ListTagsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetTagHandler(w http.ResponseWriter, r *http.Request) {
+func GetCommitDiffHandler(w http.ResponseWriter, r *http.Request) {
 	repositoryName, ok := getVar(w, r, "repository")
 	if !ok {
 		return
 	}
 
-	tagName, ok := getVar(w, r, "tag")
+	commitOid, ok := getVar(w, r, "commit")
 	if !ok {
 		return
 	}
 
-	tag, err := repository.LookupTag(repositoryName, tagName)
+	parent, ok := getQueryInt(w, r, "parent")
+	if !ok {
+		return
+	}
+	if parent == 0 {
+		parent = 1
+	}
+
+	diff, err := repository.DiffCommit(repositoryName, commitOid, parent, diffOptions(r))
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 
-	data, err := json.Marshal(buildTagModel(tag))
+	writeDiff(w, r, diff)
+}
+
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	revisions, ok := getVar(w, r, "revisions")
+	if !ok {
+		return
+	}
+
+	// split on the last "...", references never containing ".." themselves
+	separator := strings.LastIndex(revisions, "...")
+	if separator <= 0 || separator+3 == len(revisions) {
+		http.Error(w, "expected base...head", http.StatusBadRequest)
+		return
+	}
+
+	base, head := revisions[:separator], revisions[separator+3:]
+	diff, err := repository.CompareRevisions(repositoryName, base, head, diffOptions(r))
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeDiff(w, r, diff)
+}
+
+// diffOptions - Options of a diff request, the patch being only formatted for format=patch
+func diffOptions(r *http.Request) repository.DiffOptions {
+	return repository.DiffOptions{Patch: r.URL.Query().Get("format") == "patch"}
+}
+
+// writeDiff - Write the diff as JSON, or as a unified patch when format=patch is requested
+func writeDiff(w http.ResponseWriter, r *http.Request, diff *repository.Diff) {
+	if diffOptions(r).Patch {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write(diff.Patch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data, err := json.Marshal(buildDiffModel(diff))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -333,3 +654,1511 @@ func GetTagHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func GetTreeHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	treeOid, ok := getVar(w, r, "tree")
+	if !ok {
+		return
+	}
+
+	options, ok := getTreeOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tree, err := repository.LookupTree(repositoryName, treeOid, options)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildTreeModel(tree))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetTreeByPathHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	ref, ok := getVar(w, r, "ref")
+	if !ok {
+		return
+	}
+
+	path, ok := getVar(w, r, "path")
+	if !ok {
+		return
+	}
+
+	options, ok := getTreeOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tree, err := repository.LookupTreeByPath(repositoryName, ref, path, options)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildTreeModel(tree))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetBlobHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	blobOid, ok := getVar(w, r, "blob")
+	if !ok {
+		return
+	}
+
+	blob, err := repository.LookupBlob(repositoryName, blobOid)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildBlobModel(blob))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetBlobByPathHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	ref, ok := getVar(w, r, "ref")
+	if !ok {
+		return
+	}
+
+	path, ok := getVar(w, r, "path")
+	if !ok {
+		return
+	}
+
+	blob, err := repository.LookupBlobByPath(repositoryName, ref, path)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildBlobModel(blob))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetRawBlobHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	ref, ok := getVar(w, r, "ref")
+	if !ok {
+		return
+	}
+
+	blobPath, ok := getVar(w, r, "path")
+	if !ok {
+		return
+	}
+
+	blob, err := repository.OpenRawBlob(repositoryName, ref, blobPath)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	defer blob.Close()
+
+	// large files take longer to send than the server's write timeout allows
+	disableDeadlines(w)
+
+	name := path.Base(blobPath)
+	w.Header().Set("Content-Type", detectContentType(name, blob.Head, blob.IsBinary))
+	w.Header().Set("ETag", fmt.Sprintf("%q", blob.Oid.String()))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+
+	// handles Range, If-Range and If-None-Match against the ETag
+	http.ServeContent(w, r, name, time.Time{}, blob)
+}
+
+func GetBlameHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	ref, ok := getVar(w, r, "ref")
+	if !ok {
+		return
+	}
+
+	blamePath, ok := getVar(w, r, "path")
+	if !ok {
+		return
+	}
+
+	start, ok := getQueryInt(w, r, "start")
+	if !ok {
+		return
+	}
+
+	end, ok := getQueryInt(w, r, "end")
+	if !ok {
+		return
+	}
+
+	hunks, err := repository.BlameFile(repositoryName, ref, blamePath, repository.BlameOptions{
+		StartLine:   start,
+		EndLine:     end,
+		FirstParent: r.URL.Query().Get("first_parent") == "true",
+	})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildBlameModel(blamePath, hunks))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func SearchCodeHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	searchContext, ok := getQueryInt(w, r, "context")
+	if !ok {
+		return
+	}
+
+	limit, ok := getQueryInt(w, r, "limit")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	result, err := repository.SearchCode(repositoryName, repository.SearchOptions{
+		Query:         query.Get("q"),
+		Regex:         query.Get("regex") == "true",
+		CaseSensitive: query.Get("case_sensitive") == "true",
+		Revision:      query.Get("ref"),
+		Paths:         query["path"],
+		Context:       searchContext,
+		Limit:         limit,
+	})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildSearchResultModel(result))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	searchContext, ok := getQueryInt(w, r, "context")
+	if !ok {
+		return
+	}
+
+	limit, ok := getQueryInt(w, r, "limit")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	repos, err := searchableRepositories(getPrincipal(r), query["repository"])
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	results := &index.SearchResults{}
+	if len(repos) > 0 {
+		results, err = codeIndex.Search(index.SearchOptions{
+			Query:         query.Get("q"),
+			Regex:         query.Get("regex") == "true",
+			CaseSensitive: query.Get("case_sensitive") == "true",
+			Repositories:  repos,
+			Paths:         query["path"],
+			Context:       searchContext,
+			Limit:         limit,
+		})
+		if err != nil {
+			handleError(err, w)
+			return
+		}
+	}
+
+	data, err := json.Marshal(buildIndexSearchModel(results))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	ref, ok := getVar(w, r, "ref")
+	if !ok {
+		return
+	}
+
+	format, ok := getVar(w, r, "format")
+	if !ok {
+		return
+	}
+
+	name, err := repository.ParseRepositoryName(repositoryName)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	// the namespaces would put slashes in the file name and nest the root folder
+	archiveName := fmt.Sprintf("%s-%s", name.Base(), ref)
+
+	query := r.URL.Query()
+	prefix := archiveName + "/"
+	if query.Has("prefix") {
+		prefix = query.Get("prefix")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+	}
+
+	contentType := "application/x-tar"
+	switch repository.ArchiveFormat(format) {
+	case repository.ArchiveTarGz:
+		contentType = "application/gzip"
+	case repository.ArchiveZip:
+		contentType = "application/zip"
+	}
+
+	disableDeadlines(w)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": archiveName + "." + format,
+	}))
+
+	writer := &flushWriter{w: w}
+	buffered := bufio.NewWriterSize(writer, 32*1024)
+	err = repository.WriteArchive(repositoryName, ref, query.Get("path"), prefix, repository.ArchiveFormat(format), buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		if !writer.started {
+			w.Header().Del("Content-Disposition")
+			handleError(err, w)
+			return
+		}
+		log.Printf("unable to write archive of %s at %s: %v", repositoryName, ref, err)
+	}
+}
+
+func ListTagsHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	tags, err := repository.ListRepositoryTags(repositoryName)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	if len(tags) > 0 {
+		data, err := json.Marshal(TagListModel{Tags: tags})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func GetTagHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	tagName, ok := getVar(w, r, "tag")
+	if !ok {
+		return
+	}
+
+	tag, err := repository.LookupTag(repositoryName, tagName)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildTagModel(tag))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request CreateTagModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Tag == "" || request.Revision == "" {
+		http.Error(w, "invalid tag request", http.StatusBadRequest)
+		return
+	}
+
+	tagger, err := parseSignature(request.Tagger)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tag, err := repository.CreateTag(repositoryName, request.Tag, request.Revision, request.Message, tagger)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildTagModel(tag))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", request.Tag)
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	tagName, ok := getVar(w, r, "tag")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteTag(repositoryName, tagName)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Webhook handlers serve both the global webhooks and the ones of a repository, depending on the route
+
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := repository.ListWebhooks(mux.Vars(r)["repository"])
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	dto := []*WebhookModel{}
+	for _, webhook := range webhooks {
+		dto = append(dto, buildWebhookModel(webhook))
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request CreateWebhookModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.URL == "" {
+		http.Error(w, "invalid webhook request", http.StatusBadRequest)
+		return
+	}
+
+	var events []repository.EventType
+	for _, event := range request.Events {
+		events = append(events, repository.EventType(event))
+	}
+
+	webhook, err := repository.CreateWebhook(mux.Vars(r)["repository"], request.URL, request.Secret, events)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildWebhookModel(webhook))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", webhook.Id)
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := getVar(w, r, "hook")
+	if !ok {
+		return
+	}
+
+	webhook, err := repository.GetWebhook(mux.Vars(r)["repository"], id)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildWebhookModel(webhook))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := getVar(w, r, "hook")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteWebhook(mux.Vars(r)["repository"], id)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := getVar(w, r, "hook")
+	if !ok {
+		return
+	}
+
+	deliveries, err := repository.ListWebhookDeliveries(mux.Vars(r)["repository"], id)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	dto := []*WebhookDeliveryModel{}
+	for _, delivery := range deliveries {
+		dto = append(dto, buildWebhookDeliveryModel(delivery))
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func ListTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := auth.ListTokens(getPrincipal(r))
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	dto := []*TokenModel{}
+	for _, token := range tokens {
+		dto = append(dto, buildTokenModel(token))
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request CreateTokenModel
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		http.Error(w, "invalid token request", http.StatusBadRequest)
+		return
+	}
+
+	var expires time.Time
+	if request.Expires != "" {
+		expires, err = time.Parse(time.RFC3339, request.Expires)
+		if err != nil {
+			http.Error(w, "invalid token expiration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var scopes []auth.Scope
+	for _, scope := range request.Scopes {
+		scopes = append(scopes, auth.Scope(scope))
+	}
+
+	token, raw, err := auth.CreateToken(getPrincipal(r), request.User, request.Name, scopes, expires)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(CreatedTokenModel{TokenModel: buildTokenModel(token), Token: raw})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", token.Id)
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := getVar(w, r, "token")
+	if !ok {
+		return
+	}
+
+	err := auth.DeleteToken(getPrincipal(r), id)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ListUsersHandler(w http.ResponseWriter, _ *http.Request) {
+	users, err := auth.ListUsers()
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	dto := []*UserModel{}
+	for _, user := range users {
+		dto = append(dto, buildUserModel(user))
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request UserModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Name == "" {
+		http.Error(w, "invalid user name", http.StatusBadRequest)
+		return
+	}
+
+	user, err := auth.CreateUser(request.Name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildUserModel(user))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", user.Name)
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "user")
+	if !ok {
+		return
+	}
+
+	user, err := auth.GetUser(name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildUserModel(user))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "user")
+	if !ok {
+		return
+	}
+
+	err := auth.DeleteUser(name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ListTeamsHandler(w http.ResponseWriter, _ *http.Request) {
+	teams, err := auth.ListTeams()
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	dto := []*TeamModel{}
+	for _, team := range teams {
+		dto = append(dto, buildTeamModel(team))
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func CreateTeamHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request TeamModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Name == "" {
+		http.Error(w, "invalid team name", http.StatusBadRequest)
+		return
+	}
+
+	team, err := auth.CreateTeam(request.Name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildTeamModel(team))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", team.Name)
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetTeamHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "team")
+	if !ok {
+		return
+	}
+
+	team, err := auth.GetTeam(name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildTeamModel(team))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteTeamHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "team")
+	if !ok {
+		return
+	}
+
+	err := auth.DeleteTeam(name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func AddTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "team")
+	if !ok {
+		return
+	}
+
+	user, ok := getVar(w, r, "user")
+	if !ok {
+		return
+	}
+
+	team, err := auth.AddTeamMember(name, user)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeTeam(w, team)
+}
+
+func RemoveTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "team")
+	if !ok {
+		return
+	}
+
+	user, ok := getVar(w, r, "user")
+	if !ok {
+		return
+	}
+
+	team, err := auth.RemoveTeamMember(name, user)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeTeam(w, team)
+}
+
+func writeTeam(w http.ResponseWriter, team *auth.Team) {
+	data, err := json.Marshal(buildTeamModel(team))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetRepositoryAccessHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	access, err := repository.GetRepositoryAccess(repositoryName)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeAccess(w, access)
+}
+
+func UpdateRepositoryAccessHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request AccessModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Visibility == "" {
+		http.Error(w, "invalid access request", http.StatusBadRequest)
+		return
+	}
+
+	access, err := repository.SetRepositoryVisibility(repositoryName, repository.Visibility(request.Visibility))
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeAccess(w, access)
+}
+
+func SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	user, ok := getVar(w, r, "user")
+	if !ok {
+		return
+	}
+
+	role, ok := getRole(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := auth.GetUser(user); err != nil {
+		handleError(err, w)
+		return
+	}
+
+	access, err := repository.SetRepositoryUserRole(repositoryName, user, role)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeAccess(w, access)
+}
+
+func DeleteUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	user, ok := getVar(w, r, "user")
+	if !ok {
+		return
+	}
+
+	access, err := repository.SetRepositoryUserRole(repositoryName, user, "")
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeAccess(w, access)
+}
+
+func SetTeamRoleHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	team, ok := getVar(w, r, "team")
+	if !ok {
+		return
+	}
+
+	role, ok := getRole(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := auth.GetTeam(team); err != nil {
+		handleError(err, w)
+		return
+	}
+
+	access, err := repository.SetRepositoryTeamRole(repositoryName, team, role)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeAccess(w, access)
+}
+
+func DeleteTeamRoleHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	team, ok := getVar(w, r, "team")
+	if !ok {
+		return
+	}
+
+	access, err := repository.SetRepositoryTeamRole(repositoryName, team, "")
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeAccess(w, access)
+}
+
+// getRole - Role of a request body, revoking roles having their own route
+func getRole(w http.ResponseWriter, r *http.Request) (repository.Role, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return "", false
+	}
+
+	var request RoleModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || !repository.Role(request.Role).IsValid() {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return "", false
+	}
+	return repository.Role(request.Role), true
+}
+
+func writeAccess(w http.ResponseWriter, access *repository.RepositoryAccess) {
+	data, err := json.Marshal(buildAccessModel(access))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func ListBranchProtectionsHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	protections, err := repository.ListBranchProtections(repositoryName)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	dto := []*BranchProtectionModel{}
+	for _, protection := range protections {
+		dto = append(dto, buildBranchProtectionModel(protection))
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetBranchProtectionHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	pattern, ok := getVar(w, r, "pattern")
+	if !ok {
+		return
+	}
+
+	protection, err := repository.GetBranchProtection(repositoryName, pattern)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeBranchProtection(w, protection)
+}
+
+func SetBranchProtectionHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	pattern, ok := getVar(w, r, "pattern")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request BranchProtectionModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || (request.Pattern != "" && request.Pattern != pattern) {
+		http.Error(w, "invalid branch protection", http.StatusBadRequest)
+		return
+	}
+
+	// only existing accounts can be allowed, their allowances going away with them
+	for _, user := range request.Users {
+		if _, err = auth.GetUser(user); err != nil {
+			handleError(err, w)
+			return
+		}
+	}
+	for _, team := range request.Teams {
+		if _, err = auth.GetTeam(team); err != nil {
+			handleError(err, w)
+			return
+		}
+	}
+
+	protection, err := repository.SetBranchProtection(repositoryName, &repository.BranchProtection{
+		Pattern:       pattern,
+		NoForcePush:   request.NoForcePush,
+		NoDelete:      request.NoDelete,
+		LinearHistory: request.LinearHistory,
+		SignedCommits: request.SignedCommits,
+		Restricted:    request.Restricted,
+		Users:         request.Users,
+		Teams:         request.Teams,
+	})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writeBranchProtection(w, protection)
+}
+
+func DeleteBranchProtectionHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	pattern, ok := getVar(w, r, "pattern")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteBranchProtection(repositoryName, pattern)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeBranchProtection(w http.ResponseWriter, protection *repository.BranchProtection) {
+	data, err := json.Marshal(buildBranchProtectionModel(protection))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func MoveRepositoryHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request MoveRepositoryModel
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		http.Error(w, "invalid move request", http.StatusBadRequest)
+		return
+	}
+
+	// administering the repository is not enough to move it into a namespace, as to create one there
+	if request.Namespace != "" && request.Namespace != "." && !getPrincipal(r).Can(auth.Admin) {
+		http.Error(w, "moving a repository into a namespace requires the "+string(auth.Admin)+" scope", http.StatusForbidden)
+		return
+	}
+
+	moved, err := repository.MoveRepository(repositoryName, request.Namespace)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	info, err := repository.GetRepositoryInfo(moved)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	data, err := json.Marshal(buildRepositoryModel(info))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Location", moved)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func ListNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	namespaces, err := repository.ListNamespaces()
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	namespaces, err = auth.VisibleNamespaces(getPrincipal(r), namespaces)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	if namespaces == nil {
+		namespaces = []string{}
+	}
+
+	data, err := json.Marshal(NamespaceListModel{Namespaces: namespaces})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func CreateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	var request NamespaceModel
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Name == "" {
+		http.Error(w, "invalid namespace name", http.StatusBadRequest)
+		return
+	}
+
+	err = repository.CreateNamespace(request.Name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.Header().Add("Location", request.Name)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func GetNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "namespace")
+	if !ok {
+		return
+	}
+
+	// namespaces are only seen by who can see one of their repositories, missing ones being
+	// reported the same way so that the names of private namespaces do not leak
+	principal := getPrincipal(r)
+	namespace, err := repository.GetNamespace(name)
+	if errors.Is(err, repository.NotFoundError) && principal == nil {
+		challenge(w)
+		return
+	}
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	visible, err := auth.VisibleNamespaces(principal, []string{namespace.Name})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	if len(visible) == 0 {
+		if principal == nil {
+			challenge(w)
+		} else {
+			handleError(repository.NotFoundError, w)
+		}
+		return
+	}
+
+	repos, err := auth.VisibleRepositories(principal, namespace.Repositories)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	namespaces, err := auth.VisibleNamespaces(principal, namespace.Namespaces)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	model := NamespaceModel{Name: namespace.Name, Namespaces: namespaces, Repositories: repos}
+	if model.Namespaces == nil {
+		model.Namespaces = []string{}
+	}
+
+	data, err := json.Marshal(model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := getVar(w, r, "namespace")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteNamespace(name)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"archive/tar"
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/repository"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestCreateCommitHandlerMoveKeepsContent(t *testing.T) {
+	if _, err := repository.CreateRepository("commit-move", ""); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"repository": "commit-move"}
+	author := &SignatureModel{Name: "Test", Email: "test@example.com"}
+
+	w := serveHandler(CreateCommitHandler, http.MethodPost, "/repositories/commit-move/commits", vars, &CreateCommitModel{
+		Branch:  "main",
+		Author:  author,
+		Message: "add",
+		Actions: []*FileActionModel{{Action: "create", Path: "old", Content: "aGVsbG8=", Encoding: "base64"}},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	// base64 without content must not turn the moved file into an empty one
+	w = serveHandler(CreateCommitHandler, http.MethodPost, "/repositories/commit-move/commits", vars, &CreateCommitModel{
+		Branch:  "main",
+		Author:  author,
+		Message: "move",
+		Actions: []*FileActionModel{{Action: "move", PreviousPath: "old", Path: "new", Encoding: "base64"}},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("move: expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	blob, err := repository.LookupBlobByPath("commit-move", "main", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blob.Contents) != "hello" {
+		t.Errorf("expected the moved content, got %q", blob.Contents)
+	}
+}
+
+func TestBranchProtectionRejections(t *testing.T) {
+	owner := createTestUser(t, "protect-owner", auth.RepoWrite)
+	writer := createTestUser(t, "protect-writer", auth.RepoWrite)
+
+	if w := serveRequest(http.MethodPost, "/repositories", owner, &RepositoryModel{Name: "protect-api"}); w.Code != http.StatusOK {
+		t.Fatalf("create: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	w := serveRequest(http.MethodPut, "/repositories/protect-api/access/users/protect-writer", owner, &RoleModel{Role: "maintain"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("grant: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	// commit - Commit a file on a branch as the token's user, returning the status and the commit
+	commit := func(token, branch, file string) (int, string) {
+		w := serveRequest(http.MethodPost, "/repositories/protect-api/commits", token, &CreateCommitModel{
+			Branch:  branch,
+			Author:  &SignatureModel{Name: "Test", Email: "test@example.com"},
+			Message: "add " + file,
+			Actions: []*FileActionModel{{Action: "create", Path: file, Content: file}},
+		})
+		return w.Code, w.Header().Get("Location")
+	}
+
+	// stable is never the default branch, which could not be deleted whatever the protections
+	_, base := commit(owner, "stable", "README")
+	_, other := commit(owner, "other", "OTHER")
+	if base == "" || other == "" {
+		t.Fatal("unable to create the branches")
+	}
+
+	w = serveRequest(http.MethodPut, "/repositories/protect-api/protections/stable", owner, &BranchProtectionModel{
+		NoForcePush:   true,
+		NoDelete:      true,
+		LinearHistory: true,
+		Users:         []string{"protect-owner"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("protect: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	merge := createMergeCommit(t, "protect-api", base, other)
+
+	if code, _ := commit(writer, "stable", "writer"); code != http.StatusForbidden {
+		t.Errorf("pusher not allowed: expected 403, got %d", code)
+	}
+	if code, _ := commit(writer, "other", "writer"); code != http.StatusCreated {
+		t.Errorf("unprotected branch: expected 201, got %d", code)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		body     interface{}
+		expected int
+	}{
+		{"force-push", http.MethodPatch, &UpdateBranchModel{Revision: other, Force: true}, http.StatusForbidden},
+		{"merge", http.MethodPatch, &UpdateBranchModel{Revision: merge}, http.StatusForbidden},
+		{"delete", http.MethodDelete, nil, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(test.method, "/repositories/protect-api/branches/stable", owner, test.body)
+		if w.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.expected, w.Code, w.Body)
+		}
+	}
+
+	// the allowed pusher still moves the branch forward
+	if code, _ := commit(owner, "stable", "owner"); code != http.StatusCreated {
+		t.Errorf("allowed pusher: expected 201, got %d", code)
+	}
+}
+
+// createMergeCommit - Create a commit merging other into base, not on any branch, which the API cannot do
+func createMergeCommit(t *testing.T, repositoryName, base, other string) string {
+	t.Helper()
+
+	repo, err := git.OpenRepository(filepath.Join(repository.GRepositoryPrefix, repositoryName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Free()
+
+	var parents []*git.Commit
+	for _, id := range []string{base, other} {
+		oid, err := git.NewOid(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent, err := repo.LookupCommit(oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parents = append(parents, parent)
+	}
+
+	tree, err := parents[0].Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := repository.NewSignature("Test", "test@example.com", time.Now())
+	oid, err := repo.CreateCommit("", signature, signature, "merge", tree, parents...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return oid.String()
+}
+
+func TestCompareHandler(t *testing.T) {
+	administrator := createTestUser(t, "compare-admin", auth.Admin)
+	if _, err := repository.CreateRepository("compare-api", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(branch, file string) {
+		_, err := repository.CreateCommit("compare-api", repository.CommitOptions{
+			Branch:  branch,
+			Author:  repository.NewSignature("Test", "test@example.com", time.Now()),
+			Message: "add " + file,
+			Changes: []repository.FileChange{{Action: repository.FileCreate, Path: file, Content: []byte(file + "\n")}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit("main", "README")
+	if _, err := repository.CreateBranch("compare-api", "feature/x", "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	commit("feature/x", "feature")
+
+	w := serveRequest(http.MethodGet, "/repositories/compare-api/compare/main...feature/x", administrator, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "old_lineno") {
+		t.Errorf("expected added lines to have no old line number, got %s", w.Body)
+	}
+
+	var diff DiffModel
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Files) != 1 || len(diff.Files[0].Hunks) != 1 {
+		t.Fatalf("expected the feature file only, got %s", w.Body)
+	}
+	if line := diff.Files[0].Hunks[0].Lines[0]; line.NewLineno == nil || *line.NewLineno != 1 {
+		t.Errorf("expected the added line numbered in the new file, got %s", w.Body)
+	}
+
+	w = serveRequest(http.MethodGet, "/repositories/compare-api/compare/main...feature/x?format=patch", administrator, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "+feature") {
+		t.Errorf("expected the patch adding the feature file, got %d: %s", w.Code, w.Body)
+	}
+
+	// main is the merge base, nothing happened on it since
+	w = serveRequest(http.MethodGet, "/repositories/compare-api/compare/feature/x...main", administrator, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil || w.Code != http.StatusOK || diff.ChangedFiles != 0 {
+		t.Errorf("expected an empty diff, got %d: %s", w.Code, w.Body)
+	}
+
+	for _, target := range []string{"main", "main...", "...main"} {
+		if w := serveRequest(http.MethodGet, "/repositories/compare-api/compare/"+target, administrator, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestGetArchiveHandlerNamespaced(t *testing.T) {
+	administrator := createTestUser(t, "archive-admin", auth.Admin)
+	if err := repository.CreateNamespace("archive-team"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.CreateRepository("archive-team/service", ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repository.CreateCommit("archive-team/service", repository.CommitOptions{
+		Branch:  "main",
+		Author:  repository.NewSignature("Test", "test@example.com", time.Now()),
+		Message: "add README",
+		Changes: []repository.FileChange{{Action: repository.FileCreate, Path: "README", Content: []byte("hello")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveRequest(http.MethodGet, "/repositories/archive-team/service/archive/main.tar", administrator, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename=service-main.tar` {
+		t.Errorf("expected the file named after the repository alone, got %s", disposition)
+	}
+
+	header, err := tar.NewReader(w.Body).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != "service-main/" {
+		t.Errorf("expected the archive rooted at service-main/, got %s", header.Name)
+	}
+}
+
+func TestGetCommitHandlerParents(t *testing.T) {
+	if _, err := repository.CreateRepository("commit-parents", ""); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repository.CreateCommit("commit-parents", repository.CommitOptions{
+		Branch:  "main",
+		Author:  repository.NewSignature("Test", "test@example.com", time.Now()),
+		Message: "add README",
+		Changes: []repository.FileChange{{Action: repository.FileCreate, Path: "README", Content: []byte("hello")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]string{"repository": "commit-parents", "commit": commit.Commit.String()}
+	w := serveHandler(GetCommitHandler, http.MethodGet, "/repositories/commit-parents/commits/"+commit.Commit.String(), vars, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	// root commits list no parents rather than null, the single parent field staying empty
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["parents"]) != "[]" || string(fields["parent"]) != `""` {
+		t.Errorf("unexpected parents %s and parent %s", fields["parents"], fields["parent"])
+	}
+}
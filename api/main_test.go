@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/repository"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// testRouter - Routes the requests of tests go through, authentication and authorization included
+var testRouter = newRouter()
+
+// TestMain - Serve every test from one temporary storage root, the auth stores caching what they read
+func TestMain(m *testing.M) {
+	// git runs the test binary as the hook of the pushes tests serve, as it runs gituim
+	if len(os.Args) > 2 && os.Args[1] == "hook" {
+		os.Exit(repository.RunHook(os.Args[2], os.Stdin, os.Stderr))
+	}
+
+	root, err := os.MkdirTemp("", "gituim-api-")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repository.GRepositoryPrefix = root
+	repository.GDataDirectory = filepath.Join(root, ".gituim")
+	// the hooks find the storage root through the environment
+	os.Setenv("GITUIM_REPOSITORY_PREFIX", repository.GRepositoryPrefix)
+	os.Setenv("GITUIM_DATA_DIRECTORY", repository.GDataDirectory)
+
+	code := m.Run()
+	os.RemoveAll(root)
+	os.Exit(code)
+}
+
+// serveHandler - Run a handler on a request with the given route variables, as the router would
+func serveHandler(handler http.HandlerFunc, method, target string, vars map[string]string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	r := httptest.NewRequest(method, target, bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	handler(w, mux.SetURLVars(r, vars))
+	return w
+}
+
+// serveRequest - Run a request through the API routes, with token as bearer token when not empty
+func serveRequest(method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	r := httptest.NewRequest(method, target, bytes.NewReader(data))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, r)
+	return w
+}
+
+// createTestUser - Create a user along with a token granting scopes, returning the raw token
+func createTestUser(t *testing.T, name string, scopes ...auth.Scope) string {
+	t.Helper()
+
+	if _, err := auth.CreateUser(name); err != nil {
+		t.Fatal(err)
+	}
+
+	administrator := &auth.Principal{Scopes: []auth.Scope{auth.Admin}}
+	_, raw, err := auth.CreateToken(administrator, name, "test", scopes, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
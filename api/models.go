@@ -1,38 +1,96 @@
 package api
 
 import (
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/index"
 	"com/gitlab/gituim/repository"
 	"encoding/base64"
+	"fmt"
+	"strings"
 	"time"
+
+	git "github.com/libgit2/git2go/v34"
 )
 
 type RepositoryModel struct {
-	Name   string `json:"name"`
-	IsBare bool   `json:"bare"`
+	Name          string            `json:"name"`
+	IsBare        bool              `json:"bare"`
+	Description   string            `json:"description"`
+	DefaultBranch string            `json:"default_branch,omitempty"`
+	Topics        []string          `json:"topics"`
+	Properties    map[string]string `json:"properties"`
+}
+
+// UpdateRepositoryModel - Metadata changes, absent fields are left untouched and null properties removed
+type UpdateRepositoryModel struct {
+	Description   *string            `json:"description"`
+	DefaultBranch *string            `json:"default_branch"`
+	Topics        *[]string          `json:"topics"`
+	Properties    map[string]*string `json:"properties"`
 }
 
 type RepositoryListModel struct {
 	Repositories []string `json:"repositories"`
 }
 
+type NamespaceModel struct {
+	Name         string   `json:"name"`
+	Namespaces   []string `json:"namespaces"`
+	Repositories []string `json:"repositories"`
+}
+
+type NamespaceListModel struct {
+	Namespaces []string `json:"namespaces"`
+}
+
+type MoveRepositoryModel struct {
+	Namespace string `json:"namespace"`
+}
+
 type BranchListModel struct {
 	Branches []*BranchModel `json:"branches"`
 }
 
 type BranchModel struct {
-	Branch string `json:"branch"`
-	Commit string `json:"commit"`
-	Tree   string `json:"tree"`
-	Parent string `json:"parent"`
+	Branch  string   `json:"branch"`
+	Commit  string   `json:"commit"`
+	Tree    string   `json:"tree"`
+	Parent  string   `json:"parent"`
+	Parents []string `json:"parents"`
+}
+
+type CreateBranchModel struct {
+	Branch   string `json:"branch"`
+	Revision string `json:"revision"`
+}
+
+type UpdateBranchModel struct {
+	Name     string `json:"name"`
+	Revision string `json:"revision"`
+	Expected string `json:"expected"`
+	Force    bool   `json:"force"`
 }
 
 type TagListModel struct {
 	Tags []string `json:"tags"`
 }
 
+type CreateTagModel struct {
+	Tag      string          `json:"tag"`
+	Revision string          `json:"revision"`
+	Message  string          `json:"message"`
+	Tagger   *SignatureModel `json:"tagger"`
+}
+
 type TagModel struct {
-	Tag    string       `json:"tag"`
-	Commit *CommitModel `json:"commit"`
+	Tag        string          `json:"tag"`
+	Annotated  bool            `json:"annotated"`
+	Oid        string          `json:"oid"`
+	Tagger     *SignatureModel `json:"tagger,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Target     string          `json:"target"`
+	TargetType string          `json:"target_type"`
+	Commit     *CommitModel    `json:"commit,omitempty"`
 }
 
 type CommitModel struct {
@@ -40,20 +98,228 @@ type CommitModel struct {
 	ShortId   string          `json:"short_id"`
 	Tree      string          `json:"tree"`
 	Parent    string          `json:"parent"`
+	Parents   []string        `json:"parents"`
 	Message   string          `json:"message"`
 	Author    *SignatureModel `json:"author"`
 	Committer *SignatureModel `json:"committer"`
+	Path      string          `json:"path,omitempty"`
+}
+
+type CommitListModel struct {
+	Commits []*CommitModel `json:"commits"`
+	Next    string         `json:"next,omitempty"`
+}
+
+type DiffModel struct {
+	Base         string           `json:"base,omitempty"`
+	Head         string           `json:"head"`
+	ChangedFiles int              `json:"changed_files"`
+	Additions    int              `json:"additions"`
+	Deletions    int              `json:"deletions"`
+	Files        []*DiffFileModel `json:"files"`
+}
+
+type DiffFileModel struct {
+	Status     string           `json:"status"`
+	OldPath    string           `json:"old_path"`
+	NewPath    string           `json:"new_path"`
+	OldOid     string           `json:"old_oid"`
+	NewOid     string           `json:"new_oid"`
+	OldMode    string           `json:"old_mode"`
+	NewMode    string           `json:"new_mode"`
+	Similarity int              `json:"similarity,omitempty"`
+	IsBinary   bool             `json:"is_binary"`
+	Additions  int              `json:"additions"`
+	Deletions  int              `json:"deletions"`
+	Hunks      []*DiffHunkModel `json:"hunks,omitempty"`
+}
+
+type DiffHunkModel struct {
+	Header   string           `json:"header"`
+	OldStart int              `json:"old_start"`
+	OldLines int              `json:"old_lines"`
+	NewStart int              `json:"new_start"`
+	NewLines int              `json:"new_lines"`
+	Lines    []*DiffLineModel `json:"lines"`
+}
+
+// DiffLineModel - Line of a hunk, without the line number of the side it is missing from
+type DiffLineModel struct {
+	Origin    string `json:"origin"`
+	OldLineno *int   `json:"old_lineno,omitempty"`
+	NewLineno *int   `json:"new_lineno,omitempty"`
+	Content   string `json:"content"`
+}
+
+type CreateCommitModel struct {
+	Branch    string             `json:"branch"`
+	Parent    string             `json:"parent"`
+	Author    *SignatureModel    `json:"author"`
+	Committer *SignatureModel    `json:"committer"`
+	Message   string             `json:"message"`
+	Actions   []*FileActionModel `json:"actions"`
+}
+
+type FileActionModel struct {
+	Action       string `json:"action"`
+	Path         string `json:"path"`
+	PreviousPath string `json:"previous_path"`
+	Content      string `json:"content"`
+	Encoding     string `json:"encoding"`
+	Executable   *bool  `json:"executable"`
+}
+
+type BlameModel struct {
+	Path  string            `json:"path"`
+	Hunks []*BlameHunkModel `json:"hunks"`
+}
+
+type BlameHunkModel struct {
+	Commit     string          `json:"commit"`
+	Author     *SignatureModel `json:"author"`
+	OrigPath   string          `json:"orig_path"`
+	OrigStart  int             `json:"orig_start"`
+	FinalStart int             `json:"final_start"`
+	Lines      int             `json:"lines"`
+	Boundary   bool            `json:"boundary"`
+	Content    []string        `json:"content"`
+}
+
+type SearchResultModel struct {
+	Tree      string             `json:"tree"`
+	Files     []*SearchFileModel `json:"files"`
+	Truncated bool               `json:"truncated"`
+}
+
+type SearchFileModel struct {
+	Path    string              `json:"path"`
+	Oid     string              `json:"oid"`
+	Matches []*SearchMatchModel `json:"matches"`
+}
+
+type SearchMatchModel struct {
+	Line    int      `json:"line"`
+	Column  int      `json:"column"`
+	Length  int      `json:"length"`
+	Content string   `json:"content"`
+	Before  []string `json:"before,omitempty"`
+	After   []string `json:"after,omitempty"`
+}
+
+type IndexSearchModel struct {
+	Results   []*IndexSearchResultModel `json:"results"`
+	Total     int                       `json:"total"`
+	Truncated bool                      `json:"truncated"`
+}
+
+type IndexSearchResultModel struct {
+	Repository string              `json:"repository"`
+	Path       string              `json:"path"`
+	Oid        string              `json:"oid"`
+	Score      float64             `json:"score"`
+	Matches    []*SearchMatchModel `json:"matches"`
+}
+
+type WebhookModel struct {
+	Id        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	HasSecret bool     `json:"has_secret"`
+	Created   string   `json:"created"`
+}
+
+type CreateWebhookModel struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+type WebhookDeliveryModel struct {
+	Id         string `json:"id"`
+	Event      string `json:"event"`
+	Repository string `json:"repository"`
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Delivered  string `json:"delivered"`
+}
+
+type TokenModel struct {
+	Id      string   `json:"id"`
+	User    string   `json:"user"`
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+	Created string   `json:"created"`
+	Expires string   `json:"expires,omitempty"`
+}
+
+type CreateTokenModel struct {
+	User    string   `json:"user"`
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+	Expires string   `json:"expires"`
+}
+
+// CreatedTokenModel - Token along with its raw value, only ever shown once
+type CreatedTokenModel struct {
+	*TokenModel
+	Token string `json:"token"`
+}
+
+type UserModel struct {
+	Name    string `json:"name"`
+	Created string `json:"created,omitempty"`
+}
+
+type TeamModel struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	Created string   `json:"created,omitempty"`
+}
+
+type AccessModel struct {
+	Visibility string            `json:"visibility"`
+	Users      map[string]string `json:"users"`
+	Teams      map[string]string `json:"teams"`
+}
+
+type RoleModel struct {
+	Role string `json:"role"`
+}
+
+type BranchProtectionModel struct {
+	Pattern       string   `json:"pattern"`
+	NoForcePush   bool     `json:"no_force_push"`
+	NoDelete      bool     `json:"no_delete"`
+	LinearHistory bool     `json:"linear_history"`
+	SignedCommits bool     `json:"signed_commits"`
+	Restricted    bool     `json:"restricted"`
+	Users         []string `json:"users"`
+	Teams         []string `json:"teams"`
 }
 
 type TreeModel struct {
 	Tree    string            `json:"tree"`
+	Path    string            `json:"path"`
 	Entries []*TreeEntryModel `json:"entries"`
+	Next    string            `json:"next,omitempty"`
 }
 
 type TreeEntryModel struct {
-	Oid      string `json:"oid"`
-	FileName string `json:"file_name"`
-	Type     string `json:"type"`
+	Oid        string           `json:"oid"`
+	FileName   string           `json:"file_name"`
+	Path       string           `json:"path"`
+	Mode       string           `json:"mode"`
+	Type       string           `json:"type"`
+	Size       *int64           `json:"size,omitempty"`
+	LastCommit *LastCommitModel `json:"last_commit,omitempty"`
+}
+
+type LastCommitModel struct {
+	Commit  string          `json:"commit"`
+	Summary string          `json:"summary"`
+	Author  *SignatureModel `json:"author"`
 }
 
 type SignatureModel struct {
@@ -69,39 +335,84 @@ type BlobModel struct {
 	Contents string `json:"contents"`
 }
 
+func buildBranchModel(branch *repository.Branch) *BranchModel {
+	parents := buildParentIds(branch.Parents)
+	return &BranchModel{
+		Branch:  branch.Branch,
+		Commit:  branch.Commit.String(),
+		Tree:    branch.Tree.String(),
+		Parent:  firstParent(parents),
+		Parents: parents,
+	}
+}
+
 func buildCommitModel(commit *repository.Commit) *CommitModel {
+	parents := buildParentIds(commit.Parents)
 	return &CommitModel{
-		Commit:  commit.Commit.String(),
-		ShortId: commit.ShortId,
-		Parent:  commit.Parent.Id().String(),
-		Message: base64.StdEncoding.EncodeToString([]byte(commit.Message)),
-		Tree:    commit.Tree.Id().String(),
-		Author: &SignatureModel{
-			Name:  commit.Author.Name,
-			Email: commit.Author.Email,
-			When:  commit.Author.When.Format(time.RFC3339),
-		},
-		Committer: &SignatureModel{
-			Name:  commit.Committer.Name,
-			Email: commit.Committer.Email,
-			When:  commit.Committer.When.Format(time.RFC3339),
-		},
+		Commit:    commit.Commit.String(),
+		ShortId:   commit.ShortId,
+		Parent:    firstParent(parents),
+		Parents:   parents,
+		Message:   base64.StdEncoding.EncodeToString([]byte(commit.Message)),
+		Tree:      commit.Tree.Id().String(),
+		Author:    buildSignatureModel(commit.Author),
+		Committer: buildSignatureModel(commit.Committer),
+	}
+}
+
+// buildParentIds - Parent ids as strings, an empty list rather than null for root commits
+func buildParentIds(parents []*git.Oid) []string {
+	ids := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		ids = append(ids, parent.String())
+	}
+	return ids
+}
+
+// firstParent - First parent id kept in the single parent field, empty for root commits
+func firstParent(parents []string) string {
+	if len(parents) == 0 {
+		return ""
+	}
+	return parents[0]
+}
+
+func buildSignatureModel(signature *git.Signature) *SignatureModel {
+	return &SignatureModel{
+		Name:  signature.Name,
+		Email: signature.Email,
+		When:  signature.When.Format(time.RFC3339),
 	}
 }
 
 func buildTreeModel(tree *repository.Tree) *TreeModel {
 	var entries []*TreeEntryModel
 	for _, entry := range tree.Entries {
-		entries = append(entries, &TreeEntryModel{
-			Oid:      entry.Id.String(),
+		model := &TreeEntryModel{
+			Oid:      entry.Oid.String(),
 			FileName: entry.Name,
+			Path:     entry.Path,
+			Mode:     fmt.Sprintf("%06o", entry.Mode),
 			Type:     entry.Type.String(),
-		})
+		}
+		if entry.Size >= 0 {
+			model.Size = &entry.Size
+		}
+		if entry.LastCommit != nil {
+			model.LastCommit = &LastCommitModel{
+				Commit:  entry.LastCommit.Commit,
+				Summary: entry.LastCommit.Summary,
+				Author:  buildSignatureModel(entry.LastCommit.Author),
+			}
+		}
+		entries = append(entries, model)
 	}
 
 	return &TreeModel{
 		Tree:    tree.Tree.String(),
+		Path:    tree.Path,
 		Entries: entries,
+		Next:    tree.Next,
 	}
 }
 
@@ -115,8 +426,239 @@ func buildBlobModel(blob *repository.Blob) *BlobModel {
 }
 
 func buildTagModel(tag *repository.Tag) *TagModel {
-	return &TagModel{
-		Tag:    tag.Tag,
-		Commit: buildCommitModel(tag.Commit),
+	model := &TagModel{
+		Tag:        tag.Tag,
+		Annotated:  tag.Annotated,
+		Oid:        tag.Oid.String(),
+		Target:     tag.Target.String(),
+		TargetType: strings.ToLower(tag.TargetType.String()),
+	}
+
+	if tag.Annotated {
+		model.Message = base64.StdEncoding.EncodeToString([]byte(tag.Message))
+		if tag.Tagger != nil {
+			model.Tagger = buildSignatureModel(tag.Tagger)
+		}
+	}
+
+	if tag.Commit != nil {
+		model.Commit = buildCommitModel(tag.Commit)
+	}
+
+	return model
+}
+
+func buildDiffModel(diff *repository.Diff) *DiffModel {
+	model := &DiffModel{
+		Head:         diff.Head.String(),
+		ChangedFiles: len(diff.Files),
+		Additions:    diff.Additions,
+		Deletions:    diff.Deletions,
+	}
+	if diff.Base != nil {
+		model.Base = diff.Base.String()
+	}
+
+	for _, file := range diff.Files {
+		fileModel := &DiffFileModel{
+			Status:     strings.ToLower(file.Status.String()),
+			OldPath:    file.OldPath,
+			NewPath:    file.NewPath,
+			OldOid:     file.OldOid.String(),
+			NewOid:     file.NewOid.String(),
+			OldMode:    fmt.Sprintf("%06o", file.OldMode),
+			NewMode:    fmt.Sprintf("%06o", file.NewMode),
+			Similarity: file.Similarity,
+			IsBinary:   file.IsBinary,
+			Additions:  file.Additions,
+			Deletions:  file.Deletions,
+		}
+
+		for _, hunk := range file.Hunks {
+			hunkModel := &DiffHunkModel{
+				Header:   hunk.Header,
+				OldStart: hunk.OldStart,
+				OldLines: hunk.OldLines,
+				NewStart: hunk.NewStart,
+				NewLines: hunk.NewLines,
+			}
+			for _, line := range hunk.Lines {
+				hunkModel.Lines = append(hunkModel.Lines, &DiffLineModel{
+					Origin:    string(rune(line.Origin)),
+					OldLineno: lineNumber(line.OldLineno),
+					NewLineno: lineNumber(line.NewLineno),
+					Content:   line.Content,
+				})
+			}
+			fileModel.Hunks = append(fileModel.Hunks, hunkModel)
+		}
+
+		model.Files = append(model.Files, fileModel)
+	}
+
+	return model
+}
+
+// lineNumber - Line number of a side of a diff, nil for the -1 of libgit2 when the side has no such line
+func lineNumber(lineno int) *int {
+	if lineno < 0 {
+		return nil
+	}
+	return &lineno
+}
+
+func buildBlameModel(path string, hunks []*repository.BlameHunk) *BlameModel {
+	model := &BlameModel{Path: path, Hunks: []*BlameHunkModel{}}
+	for _, hunk := range hunks {
+		hunkModel := &BlameHunkModel{
+			Commit:     hunk.Commit.String(),
+			OrigPath:   hunk.OrigPath,
+			OrigStart:  hunk.OrigStart,
+			FinalStart: hunk.FinalStart,
+			Lines:      hunk.Lines,
+			Boundary:   hunk.Boundary,
+			Content:    hunk.Content,
+		}
+		if hunk.Author != nil {
+			hunkModel.Author = buildSignatureModel(hunk.Author)
+		}
+		model.Hunks = append(model.Hunks, hunkModel)
+	}
+	return model
+}
+
+func buildSearchResultModel(result *repository.SearchResult) *SearchResultModel {
+	model := &SearchResultModel{Tree: result.Tree.String(), Files: []*SearchFileModel{}, Truncated: result.Truncated}
+	for _, file := range result.Files {
+		model.Files = append(model.Files, &SearchFileModel{
+			Path:    file.Path,
+			Oid:     file.Oid.String(),
+			Matches: buildSearchMatchModels(file.Matches),
+		})
+	}
+	return model
+}
+
+func buildIndexSearchModel(results *index.SearchResults) *IndexSearchModel {
+	model := &IndexSearchModel{Results: []*IndexSearchResultModel{}, Total: results.Total, Truncated: results.Truncated}
+	for _, result := range results.Results {
+		model.Results = append(model.Results, &IndexSearchResultModel{
+			Repository: result.Repository,
+			Path:       result.Path,
+			Oid:        result.Oid,
+			Score:      result.Score,
+			Matches:    buildSearchMatchModels(result.Matches),
+		})
+	}
+	return model
+}
+
+func buildSearchMatchModels(matches []*repository.SearchMatch) []*SearchMatchModel {
+	models := []*SearchMatchModel{}
+	for _, match := range matches {
+		models = append(models, &SearchMatchModel{
+			Line:    match.Line,
+			Column:  match.Column,
+			Length:  match.Length,
+			Content: match.Content,
+			Before:  match.Before,
+			After:   match.After,
+		})
+	}
+	return models
+}
+
+func buildWebhookModel(webhook *repository.Webhook) *WebhookModel {
+	model := &WebhookModel{
+		Id:        webhook.Id,
+		URL:       webhook.URL,
+		Events:    []string{},
+		HasSecret: webhook.Secret != "",
+		Created:   webhook.Created.Format(time.RFC3339),
+	}
+	for _, event := range webhook.Events {
+		model.Events = append(model.Events, string(event))
+	}
+	return model
+}
+
+func buildWebhookDeliveryModel(delivery *repository.WebhookDelivery) *WebhookDeliveryModel {
+	return &WebhookDeliveryModel{
+		Id:         delivery.Id,
+		Event:      string(delivery.Event),
+		Repository: delivery.Repository,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		DurationMs: delivery.Duration.Milliseconds(),
+		Delivered:  delivery.Delivered.Format(time.RFC3339),
+	}
+}
+
+func buildTokenModel(token *auth.Token) *TokenModel {
+	model := &TokenModel{
+		Id:      token.Id,
+		User:    token.User,
+		Name:    token.Name,
+		Scopes:  []string{},
+		Created: token.Created.Format(time.RFC3339),
+	}
+	for _, scope := range token.Scopes {
+		model.Scopes = append(model.Scopes, string(scope))
+	}
+	if !token.Expires.IsZero() {
+		model.Expires = token.Expires.Format(time.RFC3339)
+	}
+	return model
+}
+
+func buildUserModel(user *auth.User) *UserModel {
+	return &UserModel{Name: user.Name, Created: user.Created.Format(time.RFC3339)}
+}
+
+func buildTeamModel(team *auth.Team) *TeamModel {
+	return &TeamModel{
+		Name:    team.Name,
+		Members: append([]string{}, team.Members...),
+		Created: team.Created.Format(time.RFC3339),
+	}
+}
+
+func buildAccessModel(access *repository.RepositoryAccess) *AccessModel {
+	model := &AccessModel{
+		Visibility: string(access.Visibility),
+		Users:      map[string]string{},
+		Teams:      map[string]string{},
+	}
+	for user, role := range access.Users {
+		model.Users[user] = string(role)
+	}
+	for team, role := range access.Teams {
+		model.Teams[team] = string(role)
+	}
+	return model
+}
+
+func buildBranchProtectionModel(protection *repository.BranchProtection) *BranchProtectionModel {
+	return &BranchProtectionModel{
+		Pattern:       protection.Pattern,
+		NoForcePush:   protection.NoForcePush,
+		NoDelete:      protection.NoDelete,
+		LinearHistory: protection.LinearHistory,
+		SignedCommits: protection.SignedCommits,
+		Restricted:    protection.Restricted,
+		Users:         append([]string{}, protection.Users...),
+		Teams:         append([]string{}, protection.Teams...),
+	}
+}
+
+func buildRepositoryModel(info *repository.Repository) *RepositoryModel {
+	return &RepositoryModel{
+		Name:          info.Repository,
+		IsBare:        info.IsBare,
+		Description:   info.Description,
+		DefaultBranch: info.DefaultBranch,
+		Topics:        info.Topics,
+		Properties:    info.Properties,
 	}
 }
@@ -1,36 +1,48 @@
 package api
 
 import (
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/index"
+	"com/gitlab/gituim/repository"
+	"com/gitlab/gituim/webhook"
 	"context"
 	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+const (
+	// repositoryPath - Repositories nested in namespaces, the shortest match wins
+	repositoryPath = "/repositories/{repository:(?:[^/]+/)*?[^/]+}"
+	namespacePath  = "/namespaces/{namespace:.+}"
+)
+
+// codeIndex - Cross repository code search index, kept up to date in the background
+var codeIndex *index.Index
+
 func InitializeServer() {
 	var wait time.Duration
+	var indexInterval time.Duration
 	flag.DurationVar(&wait, "graceful-timeout", time.Second*15, "the duration for which the server gracefully wait for existing connections to finish - e.g. 15s or 1m")
+	flag.DurationVar(&indexInterval, "index-interval", time.Minute*5, "the duration between two checks of every repository by the code search index - e.g. 30s or 5m")
 	flag.Parse()
 
-	router := mux.NewRouter()
-	router.HandleFunc("/repositories", ListRepositoriesHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories", CreateRepositoryHandler).Methods(http.MethodPost)
-	router.HandleFunc("/repositories/{repository}", GetRepositoryInfoHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}", DeleteRepositoryHandler).Methods(http.MethodDelete)
-	router.HandleFunc("/repositories/{repository}/branches", ListBranchesHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}/branches/{branch}", GetBranchHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}/commits/{commit}", GetCommitHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}/tree/{tree}", GetTreeHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}/blobs/{blob}", GetBlobHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}/tags", ListTagsHandler).Methods(http.MethodGet)
-	router.HandleFunc("/repositories/{repository}/tags/{tag}", GetTagHandler).Methods(http.MethodGet)
+	if auth.GAdminToken == "" {
+		log.Println("GITUIM_ADMIN_TOKEN is not set, only existing tokens are accepted")
+	}
+
+	codeIndex = index.NewIndex()
+	codeIndex.Start(indexInterval)
+	webhook.Start()
+
 	srv := &http.Server{
-		Handler:      router,
+		Handler:      newRouter(),
 		Addr:         "0.0.0.0:8080",
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
@@ -59,3 +71,108 @@ func InitializeServer() {
 	log.Println("shutting down")
 	os.Exit(0)
 }
+
+// newRouter - Routes of the API along with the authentication and authorization they require
+func newRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/search", anonymous(SearchHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/tokens", authenticated(auth.RepoRead, ListTokensHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/tokens", authenticated(auth.RepoRead, CreateTokenHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/tokens/{token}", authenticated(auth.RepoRead, DeleteTokenHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/users", authenticated(auth.Admin, ListUsersHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/users", authenticated(auth.Admin, CreateUserHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{user}", authenticated(auth.Admin, GetUserHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/users/{user}", authenticated(auth.Admin, DeleteUserHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/teams", authenticated(auth.Admin, ListTeamsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/teams", authenticated(auth.Admin, CreateTeamHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/teams/{team}", authenticated(auth.Admin, GetTeamHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/teams/{team}", authenticated(auth.Admin, DeleteTeamHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/teams/{team}/members/{user}", authenticated(auth.Admin, AddTeamMemberHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/teams/{team}/members/{user}", authenticated(auth.Admin, RemoveTeamMemberHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/hooks", authenticated(auth.Admin, ListWebhooksHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/hooks", authenticated(auth.Admin, CreateWebhookHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/hooks/{hook}", authenticated(auth.Admin, GetWebhookHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/hooks/{hook}", authenticated(auth.Admin, DeleteWebhookHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/hooks/{hook}/deliveries", authenticated(auth.Admin, ListWebhookDeliveriesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/namespaces", anonymous(ListNamespacesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/namespaces", authenticated(auth.Admin, CreateNamespaceHandler)).Methods(http.MethodPost)
+	router.HandleFunc(namespacePath, anonymous(GetNamespaceHandler)).Methods(http.MethodGet)
+	router.HandleFunc(namespacePath, authenticated(auth.Admin, DeleteNamespaceHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/repositories", anonymous(ListRepositoriesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/repositories", authenticated(auth.RepoWrite, CreateRepositoryHandler)).Methods(http.MethodPost)
+	handleRepository(router, "", authorized(auth.RepoRead, repository.RoleRead, GetRepositoryInfoHandler)).Methods(http.MethodGet)
+	handleRepository(router, "", authorized(auth.RepoWrite, repository.RoleAdmin, UpdateRepositoryHandler)).Methods(http.MethodPatch)
+	handleRepository(router, "", authorized(auth.RepoWrite, repository.RoleAdmin, DeleteRepositoryHandler)).Methods(http.MethodDelete)
+	handleRepository(router, "/branches", authorized(auth.RepoRead, repository.RoleRead, ListBranchesHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/branches", authorized(auth.RepoWrite, repository.RoleWrite, CreateBranchHandler)).Methods(http.MethodPost)
+	handleRepository(router, "/branches/{branch}", authorized(auth.RepoRead, repository.RoleRead, GetBranchHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/branches/{branch}", authorized(auth.RepoWrite, repository.RoleWrite, UpdateBranchHandler)).Methods(http.MethodPatch)
+	handleRepository(router, "/branches/{branch}", authorized(auth.RepoWrite, repository.RoleMaintain, DeleteBranchHandler)).Methods(http.MethodDelete)
+	handleRepository(router, "/commits", authorized(auth.RepoRead, repository.RoleRead, ListCommitsHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/commits", authorized(auth.RepoWrite, repository.RoleWrite, CreateCommitHandler)).Methods(http.MethodPost)
+	handleRepository(router, "/commits/{commit}", authorized(auth.RepoRead, repository.RoleRead, GetCommitHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/commits/{commit}/diff", authorized(auth.RepoRead, repository.RoleRead, GetCommitDiffHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/compare/{revisions:.+}", authorized(auth.RepoRead, repository.RoleRead, CompareHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/tree/{tree}", authorized(auth.RepoRead, repository.RoleRead, GetTreeHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/tree/{ref}/{path:.*}", authorized(auth.RepoRead, repository.RoleRead, GetTreeByPathHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/blobs/{blob}", authorized(auth.RepoRead, repository.RoleRead, GetBlobHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/blob/{ref}/{path:.+}", authorized(auth.RepoRead, repository.RoleRead, GetBlobByPathHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/raw/{ref}/{path:.+}", authorized(auth.RepoRead, repository.RoleRead, GetRawBlobHandler)).Methods(http.MethodGet, http.MethodHead)
+	handleRepository(router, "/blame/{ref}/{path:.+}", authorized(auth.RepoRead, repository.RoleRead, GetBlameHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/search", authorized(auth.RepoRead, repository.RoleRead, SearchCodeHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/search/commits", authorized(auth.RepoRead, repository.RoleRead, SearchCommitsHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/archive/{ref}.{format:tar|tar\\.gz|zip}", authorized(auth.RepoRead, repository.RoleRead, GetArchiveHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/tags", authorized(auth.RepoRead, repository.RoleRead, ListTagsHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/tags", authorized(auth.RepoWrite, repository.RoleWrite, CreateTagHandler)).Methods(http.MethodPost)
+	handleRepository(router, "/tags/{tag}", authorized(auth.RepoRead, repository.RoleRead, GetTagHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/tags/{tag}", authorized(auth.RepoWrite, repository.RoleMaintain, DeleteTagHandler)).Methods(http.MethodDelete)
+	handleRepository(router, "/hooks", authorized(auth.RepoWrite, repository.RoleAdmin, ListWebhooksHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/hooks", authorized(auth.RepoWrite, repository.RoleAdmin, CreateWebhookHandler)).Methods(http.MethodPost)
+	handleRepository(router, "/hooks/{hook}", authorized(auth.RepoWrite, repository.RoleAdmin, GetWebhookHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/hooks/{hook}", authorized(auth.RepoWrite, repository.RoleAdmin, DeleteWebhookHandler)).Methods(http.MethodDelete)
+	handleRepository(router, "/hooks/{hook}/deliveries", authorized(auth.RepoWrite, repository.RoleAdmin, ListWebhookDeliveriesHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/protections", authorized(auth.RepoRead, repository.RoleRead, ListBranchProtectionsHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/protections/{pattern:.+}", authorized(auth.RepoRead, repository.RoleRead, GetBranchProtectionHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/protections/{pattern:.+}", authorized(auth.RepoWrite, repository.RoleAdmin, SetBranchProtectionHandler)).Methods(http.MethodPut)
+	handleRepository(router, "/protections/{pattern:.+}", authorized(auth.RepoWrite, repository.RoleAdmin, DeleteBranchProtectionHandler)).Methods(http.MethodDelete)
+	handleRepository(router, "/access", authorized(auth.RepoWrite, repository.RoleAdmin, GetRepositoryAccessHandler)).Methods(http.MethodGet)
+	handleRepository(router, "/access", authorized(auth.RepoWrite, repository.RoleAdmin, UpdateRepositoryAccessHandler)).Methods(http.MethodPatch)
+	handleRepository(router, "/access/users/{user}", authorized(auth.RepoWrite, repository.RoleAdmin, SetUserRoleHandler)).Methods(http.MethodPut)
+	handleRepository(router, "/access/users/{user}", authorized(auth.RepoWrite, repository.RoleAdmin, DeleteUserRoleHandler)).Methods(http.MethodDelete)
+	handleRepository(router, "/access/teams/{team}", authorized(auth.RepoWrite, repository.RoleAdmin, SetTeamRoleHandler)).Methods(http.MethodPut)
+	handleRepository(router, "/access/teams/{team}", authorized(auth.RepoWrite, repository.RoleAdmin, DeleteTeamRoleHandler)).Methods(http.MethodDelete)
+	handleRepository(router, ".git/info/refs", authorizedService(InfoRefsHandler)).Methods(http.MethodGet)
+	handleRepository(router, ".git/git-upload-pack", authorized(auth.RepoRead, repository.RoleRead, UploadPackHandler)).Methods(http.MethodPost)
+	handleRepository(router, ".git/git-receive-pack", authorized(auth.RepoWrite, repository.RoleWrite, ReceivePackHandler)).Methods(http.MethodPost)
+	handleRepository(router, "/move", authorized(auth.RepoWrite, repository.RoleAdmin, MoveRepositoryHandler)).Methods(http.MethodPost)
+	return router
+}
+
+// handleRepository - Route a resource of a repository, only taking paths whose repository name ends
+// right before the resource. The names of repositories and namespaces cannot be route words, so the
+// first one found in a path ends the name, whatever file paths or patterns follow it.
+func handleRepository(router *mux.Router, resource string, handler http.HandlerFunc) *mux.Route {
+	word, _, _ := strings.Cut(strings.TrimPrefix(resource, "/"), "/")
+	if strings.HasPrefix(word, ".git") {
+		word = ".git"
+	}
+
+	return router.HandleFunc(repositoryPath+resource, handler).MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return repositoryResource(r.URL.Path) == word
+	})
+}
+
+// repositoryResource - First route word of a repository path, .git for the git transport and empty
+// for the repository itself
+func repositoryResource(path string) string {
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/repositories/"), "/") {
+		if !repository.IsReservedName(segment) {
+			continue
+		}
+		if segment == "info" || segment == "git-upload-pack" || segment == "git-receive-pack" {
+			return ".git"
+		}
+		return segment
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"com/gitlab/gituim/repository"
+	"compress/gzip"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func InfoRefsHandler(w http.ResponseWriter, r *http.Request) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	service := repository.Service(r.URL.Query().Get("service"))
+	if !service.IsValid() {
+		http.Error(w, "unsupported service", http.StatusForbidden)
+		return
+	}
+
+	protocol := r.Header.Get("Git-Protocol")
+
+	var advertisement bytes.Buffer
+	err := repository.AdvertiseReferences(repositoryName, service, protocol, &advertisement)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// protocol v2 clients do not expect the service announcement
+	if !strings.Contains(protocol, "version=2") {
+		_, err = fmt.Fprintf(w, "%s0000", pktLine(fmt.Sprintf("# service=%s\n", service)))
+		if err != nil {
+			return
+		}
+	}
+
+	_, _ = advertisement.WriteTo(w)
+}
+
+func UploadPackHandler(w http.ResponseWriter, r *http.Request) {
+	serviceHandler(w, r, repository.UploadPack)
+}
+
+func ReceivePackHandler(w http.ResponseWriter, r *http.Request) {
+	serviceHandler(w, r, repository.ReceivePack)
+}
+
+func serviceHandler(w http.ResponseWriter, r *http.Request, service repository.Service) {
+	repositoryName, ok := getVar(w, r, "repository")
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != fmt.Sprintf("application/x-%s-request", service) {
+		http.Error(w, "invalid content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "invalid gzip body", http.StatusBadRequest)
+			return
+		}
+		defer reader.Close()
+		body = reader
+	}
+
+	// packs can take longer than the server timeouts to transfer
+	disableDeadlines(w)
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-result", service))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	pusher, err := getPusher(r)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+
+	writer := &flushWriter{w: w}
+	err = repository.ServeService(repositoryName, service, r.Header.Get("Git-Protocol"), pusher, body, writer)
+	if err != nil {
+		if !writer.started {
+			handleError(err, w)
+			return
+		}
+		log.Printf("unable to serve %s for %s: %v", service, repositoryName, err)
+	}
+}
+
+// flushWriter - Flushes every write so git progress reaches the client immediately
+type flushWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	if !f.started {
+		f.started = true
+		f.w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// disableDeadlines - Lift the server read and write timeouts for long running transfers
+func disableDeadlines(w http.ResponseWriter) {
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Time{})
+	_ = controller.SetWriteDeadline(time.Time{})
+}
+
+func pktLine(line string) string {
+	return fmt.Sprintf("%04x%s", len(line)+4, line)
+}
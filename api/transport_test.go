@@ -0,0 +1,120 @@
+package api
+
+import (
+	"com/gitlab/gituim/auth"
+	"com/gitlab/gituim/repository"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGit - Run a git command authenticated with token, returning its trimmed output
+func runGit(t *testing.T, directory, token string, args ...string) string {
+	t.Helper()
+
+	args = append([]string{"-c", "http.extraHeader=Authorization: Bearer " + token,
+		"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = directory
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args[6:], " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestInfoRefsHandler(t *testing.T) {
+	owner := createTestUser(t, "transport-refs", auth.RepoWrite)
+	if w := serveRequest(http.MethodPost, "/repositories", owner, &RepositoryModel{Name: "transport-refs"}); w.Code != http.StatusOK {
+		t.Fatalf("create: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w := serveRequest(http.MethodGet, "/repositories/transport-refs.git/info/refs?service=git-upload-pack", owner, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-git-upload-pack-advertisement" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+	if !strings.HasPrefix(w.Body.String(), "001e# service=git-upload-pack\n0000") {
+		t.Errorf("expected the service announcement, got %q", w.Body)
+	}
+
+	if w = serveRequest(http.MethodGet, "/repositories/transport-refs.git/info/refs?service=git-shell", owner, nil); w.Code != http.StatusForbidden {
+		t.Errorf("unsupported service: expected 403, got %d", w.Code)
+	}
+
+	// protocol v2 clients get the capabilities straight away
+	r := httptest.NewRequest(http.MethodGet, "/repositories/transport-refs.git/info/refs?service=git-upload-pack", nil)
+	r.Header.Set("Authorization", "Bearer "+owner)
+	r.Header.Set("Git-Protocol", "version=2")
+	w = httptest.NewRecorder()
+	testRouter.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "000eversion 2\n") {
+		t.Errorf("expected a protocol v2 advertisement, got %d: %q", w.Code, w.Body)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/repositories/transport-refs.git/git-upload-pack", strings.NewReader("0000"))
+	r.Header.Set("Authorization", "Bearer "+owner)
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	testRouter.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("invalid content type: expected 415, got %d", w.Code)
+	}
+}
+
+func TestCloneAndPush(t *testing.T) {
+	owner := createTestUser(t, "transport-owner", auth.RepoWrite)
+	reader := createTestUser(t, "transport-reader", auth.RepoRead)
+	if w := serveRequest(http.MethodPost, "/repositories", owner, &RepositoryModel{Name: "transported"}); w.Code != http.StatusOK {
+		t.Fatalf("create: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := serveRequest(http.MethodPut, "/repositories/transported/access/users/transport-reader", owner, &RoleModel{Role: "read"}); w.Code != http.StatusOK {
+		t.Fatalf("grant: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	server := httptest.NewServer(testRouter)
+	defer server.Close()
+	url := server.URL + "/repositories/transported.git"
+
+	// an empty repository is cloned, then pushed to
+	directory := t.TempDir()
+	runGit(t, directory, owner, "clone", url, "clone")
+	clone := filepath.Join(directory, "clone")
+	if err := os.WriteFile(filepath.Join(clone, "README"), []byte("pushed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, clone, owner, "add", "README")
+	runGit(t, clone, owner, "commit", "-m", "add README")
+	runGit(t, clone, owner, "push", "origin", "HEAD:refs/heads/main")
+	pushed := runGit(t, clone, owner, "rev-parse", "HEAD")
+
+	branch, err := repository.GetBranch("transported", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch.Commit.String() != pushed {
+		t.Errorf("expected main at the pushed %s, got %s", pushed, branch.Commit)
+	}
+
+	// readers clone but cannot push
+	runGit(t, directory, reader, "clone", "--branch", "main", url, "read")
+	content, err := os.ReadFile(filepath.Join(directory, "read", "README"))
+	if err != nil || string(content) != "pushed\n" {
+		t.Errorf("expected the pushed README, got %q: %v", content, err)
+	}
+
+	cmd := exec.Command("git", "-c", "http.extraHeader=Authorization: Bearer "+reader, "push", "origin", "HEAD:refs/heads/other")
+	cmd.Dir = filepath.Join(directory, "read")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("expected the push of a reader to be refused: %s", output)
+	}
+}
@@ -1,10 +1,18 @@
 package api
 
 import (
+	"com/gitlab/gituim/auth"
 	"com/gitlab/gituim/repository"
 	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
+	git "github.com/libgit2/git2go/v34"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func getVar(w http.ResponseWriter, r *http.Request, varName string) (string, bool) {
@@ -18,9 +26,144 @@ func getVar(w http.ResponseWriter, r *http.Request, varName string) (string, boo
 }
 
 func handleError(err error, w http.ResponseWriter) {
-	if errors.Is(err, repository.NotFoundError) {
+	switch {
+	case errors.Is(err, repository.NotFoundError):
 		http.Error(w, repository.NotFoundError.Error(), http.StatusNotFound)
-	} else {
+	case errors.Is(err, repository.AlreadyExistsError), errors.Is(err, repository.ConflictError):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.InvalidArgumentError):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, auth.UnauthorizedError):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, auth.ForbiddenError), errors.Is(err, repository.ProtectedBranchError):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// getQueryInt - Optional integer query parameter, zero when absent
+func getQueryInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, true
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		http.Error(w, "invalid "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	return number, true
+}
+
+// getQueryTime - Optional RFC 3339 or YYYY-MM-DD query parameter, zero when absent. Dates stand for
+// the start of their day, or for its very end with endOfDay so that bounds include the whole day.
+func getQueryTime(w http.ResponseWriter, r *http.Request, name string, endOfDay bool) (time.Time, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, true
+	}
+
+	http.Error(w, "invalid "+name, http.StatusBadRequest)
+	return time.Time{}, false
+}
+
+// detectContentType - Content type of a blob from its extension, falling back to sniffing.
+// Types a browser would execute are served as plain text.
+func detectContentType(name string, head []byte, binary bool) string {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/html", mediaType == "image/svg+xml", strings.HasSuffix(mediaType, "xml"),
+		strings.HasSuffix(mediaType, "javascript"):
+		return "text/plain; charset=utf-8"
+	case binary && strings.HasPrefix(mediaType, "text/"):
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// parseSignature - Signature from a request model, dated now when no time is given
+func parseSignature(model *SignatureModel) (*git.Signature, error) {
+	if model == nil {
+		return nil, nil
+	}
+
+	if model.Name == "" || model.Email == "" {
+		return nil, errors.New("signature needs a name and an email")
+	}
+
+	var when time.Time
+	if model.When != "" {
+		var err error
+		when, err = time.Parse(time.RFC3339, model.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature time: %w", err)
+		}
+	}
+
+	return repository.NewSignature(model.Name, model.Email, when), nil
+}
+
+// getTreeOptions - Tree listing options from the recursive, limit, cursor and last_commits query parameters
+func getTreeOptions(w http.ResponseWriter, r *http.Request) (repository.TreeOptions, bool) {
+	limit, ok := getQueryInt(w, r, "limit")
+	if !ok {
+		return repository.TreeOptions{}, false
+	}
+
+	query := r.URL.Query()
+	return repository.TreeOptions{
+		Recursive:   query.Get("recursive") == "true",
+		Limit:       limit,
+		Cursor:      query.Get("cursor"),
+		LastCommits: query.Get("last_commits") == "true",
+	}, true
+}
+
+// getLogOptions - Commit listing options from the query parameters
+func getLogOptions(w http.ResponseWriter, r *http.Request) (repository.LogOptions, bool) {
+	limit, ok := getQueryInt(w, r, "limit")
+	if !ok {
+		return repository.LogOptions{}, false
+	}
+
+	since, ok := getQueryTime(w, r, "since", false)
+	if !ok {
+		return repository.LogOptions{}, false
+	}
+
+	until, ok := getQueryTime(w, r, "until", true)
+	if !ok {
+		return repository.LogOptions{}, false
+	}
+
+	query := r.URL.Query()
+	return repository.LogOptions{
+		Revision:  query.Get("ref"),
+		Path:      query.Get("path"),
+		Since:     since,
+		Until:     until,
+		Author:    query.Get("author"),
+		Committer: query.Get("committer"),
+		Message:   query.Get("message"),
+		Limit:     limit,
+		Cursor:    query.Get("cursor"),
+		Follow:    query.Get("follow") == "true",
+	}, true
+}
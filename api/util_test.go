@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetLogOptionsDates(t *testing.T) {
+	tests := []struct {
+		query  string
+		inside []string
+		after  []string
+	}{
+		{"until=2024-05-01", []string{"2024-05-01T00:00:00Z", "2024-05-01T23:59:59Z"}, []string{"2024-05-02T00:00:00Z"}},
+		{"until=2024-05-01T12:00:00Z", []string{"2024-05-01T12:00:00Z"}, []string{"2024-05-01T12:00:01Z"}},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		options, ok := getLogOptions(w, httptest.NewRequest(http.MethodGet, "/?since=2024-05-01&"+test.query, nil))
+		if !ok {
+			t.Fatalf("%s: unexpected error %s", test.query, w.Body)
+		}
+
+		for _, value := range test.inside {
+			when, _ := time.Parse(time.RFC3339, value)
+			if when.Before(options.Since) || when.After(options.Until) {
+				t.Errorf("%s: expected %s within %s and %s", test.query, value, options.Since, options.Until)
+			}
+		}
+		for _, value := range test.after {
+			when, _ := time.Parse(time.RFC3339, value)
+			if !when.After(options.Until) {
+				t.Errorf("%s: expected %s after %s", test.query, value, options.Until)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	if _, ok := getLogOptions(w, httptest.NewRequest(http.MethodGet, "/?until=yesterday", nil)); ok || w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid date, got %d", w.Code)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"com/gitlab/gituim/repository"
+)
+
+// RoleFor - Role of a principal on a repository, the highest granted to them or to one of their teams.
+// Administrators hold every role, anyone reads public repositories, and the empty role means no access.
+func RoleFor(principal *Principal, repositoryName string) (repository.Role, error) {
+	access, err := repository.GetRepositoryAccess(repositoryName)
+	if err != nil {
+		return "", err
+	}
+
+	if principal.Can(Admin) {
+		return repository.RoleAdmin, nil
+	}
+
+	var role repository.Role
+	if access.Visibility == repository.Public {
+		role = repository.RoleRead
+	}
+
+	if principal == nil || principal.User == "" {
+		return role, nil
+	}
+
+	role = role.Max(access.Users[principal.User])
+
+	teams, err := TeamsOf(principal.User)
+	if err != nil {
+		return "", err
+	}
+	for _, team := range teams {
+		role = role.Max(access.Teams[team])
+	}
+
+	return role, nil
+}
+
+// VisibleRepositories - Repositories among names the principal can read
+func VisibleRepositories(principal *Principal, names []string) ([]string, error) {
+	visible := []string{}
+	for _, name := range names {
+		role, err := RoleFor(principal, name)
+		if errors.Is(err, repository.NotFoundError) || errors.Is(err, repository.InvalidArgumentError) {
+			// deleted since it was listed, or never a repository name at all
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if role.Includes(repository.RoleRead) {
+			visible = append(visible, name)
+		}
+	}
+	return visible, nil
+}
+
+// VisibleNamespaces - Namespaces among names the principal can see, the ones holding a repository they can
+// read at any depth. Administrators see every namespace, the empty ones included.
+func VisibleNamespaces(principal *Principal, names []string) ([]string, error) {
+	if principal.Can(Admin) {
+		return names, nil
+	}
+
+	repositories, err := repository.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	repositories, err = VisibleRepositories(principal, repositories)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []string{}
+	for _, name := range names {
+		for _, repositoryName := range repositories {
+			if strings.HasPrefix(repositoryName, name+"/") {
+				visible = append(visible, name)
+				break
+			}
+		}
+	}
+	return visible, nil
+}
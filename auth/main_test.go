@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"com/gitlab/gituim/repository"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain - Serve every test from one temporary storage root, the stores caching what they read
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "gituim-auth-")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repository.GRepositoryPrefix = root
+	repository.GDataDirectory = filepath.Join(root, ".gituim")
+
+	code := m.Run()
+	os.RemoveAll(root)
+	os.Exit(code)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+
+	"com/gitlab/gituim/repository"
+)
+
+// jsonStore - Items of a data directory file kept in memory, the file being rewritten on every change.
+// Items are shared with readers so changes must replace them instead of modifying them.
+type jsonStore[T any] struct {
+	name   string
+	mutex  sync.RWMutex
+	loaded bool
+	items  []*T
+}
+
+func (s *jsonStore[T]) list() ([]*T, error) {
+	s.mutex.RLock()
+	if s.loaded {
+		defer s.mutex.RUnlock()
+		return s.items, nil
+	}
+	s.mutex.RUnlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.items, nil
+}
+
+// update - Replace the items with the ones returned by change, saving them before they become visible
+func (s *jsonStore[T]) update(change func(items []*T) ([]*T, error)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	items, err := change(append([]*T{}, s.items...))
+	if err != nil {
+		return err
+	}
+
+	if err = repository.WriteDataFile(s.name, items); err != nil {
+		return fmt.Errorf("unable to save %s: %w", s.name, err)
+	}
+
+	s.items = items
+	return nil
+}
+
+// load - Read the file once, the caller holding the write lock
+func (s *jsonStore[T]) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	items := []*T{}
+	if _, err := repository.ReadDataFile(s.name, &items); err != nil {
+		return fmt.Errorf("unable to read %s: %w", s.name, err)
+	}
+
+	s.items = items
+	s.loaded = true
+	return nil
+}
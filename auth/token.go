@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"com/gitlab/gituim/repository"
+)
+
+type Scope string
+
+const (
+	RepoRead  Scope = "repo:read"
+	RepoWrite Scope = "repo:write"
+	Admin     Scope = "admin"
+)
+
+// tokenPrefix - Marks gituim tokens so they are easy to spot in leaked secrets
+const tokenPrefix = "gituim_"
+
+var (
+	UnauthorizedError = errors.New("unauthorized")
+	ForbiddenError    = errors.New("forbidden")
+
+	// GAdminToken - Token with every scope taken from the environment, to bootstrap the first tokens
+	GAdminToken = os.Getenv("GITUIM_ADMIN_TOKEN")
+)
+
+type Token struct {
+	Id      string    `json:"id"`
+	User    string    `json:"user"`
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"`
+	Scopes  []Scope   `json:"scopes"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// Principal - Whoever made a request, as identified by its token.
+// The bootstrap administrator token belongs to no user.
+type Principal struct {
+	TokenId string
+	User    string
+	Scopes  []Scope
+}
+
+var tokenStore = &jsonStore[Token]{name: "tokens.json"}
+
+// IsValid - Report whether the scope is one gituim knows
+func (s Scope) IsValid() bool {
+	return s == RepoRead || s == RepoWrite || s == Admin
+}
+
+// Allows - Report whether holding s grants scope, admin granting everything and write granting read
+func (s Scope) Allows(scope Scope) bool {
+	switch s {
+	case Admin:
+		return true
+	case RepoWrite:
+		return scope == RepoWrite || scope == RepoRead
+	default:
+		return s == scope
+	}
+}
+
+// Can - Report whether one of the scopes of the principal grants scope, anonymous principals being nil
+func (p *Principal) Can(scope Scope) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, held := range p.Scopes {
+		if held.Allows(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate - Principal a raw token belongs to, UnauthorizedError when it is unknown or expired
+func Authenticate(raw string) (*Principal, error) {
+	if raw == "" {
+		return nil, UnauthorizedError
+	}
+
+	if GAdminToken != "" && subtle.ConstantTimeCompare([]byte(raw), []byte(GAdminToken)) == 1 {
+		return &Principal{Scopes: []Scope{Admin}}, nil
+	}
+
+	tokens, err := tokenStore.list()
+	if err != nil {
+		return nil, err
+	}
+
+	// only hashes are stored, comparing them leaks nothing about the tokens
+	hash := hashToken(raw)
+	for _, token := range tokens {
+		if token.Hash != hash {
+			continue
+		}
+		if !token.Expires.IsZero() && time.Now().After(token.Expires) {
+			return nil, UnauthorizedError
+		}
+		return &Principal{TokenId: token.Id, User: token.User, Scopes: token.Scopes}, nil
+	}
+
+	return nil, UnauthorizedError
+}
+
+// ListTokens - Tokens of the actor, every token for administrators, without a way to recover the raw tokens
+func ListTokens(actor *Principal) ([]*Token, error) {
+	tokens, err := tokenStore.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if actor.Can(Admin) {
+		return tokens, nil
+	}
+
+	owned := []*Token{}
+	for _, token := range tokens {
+		if token.User == actor.User {
+			owned = append(owned, token)
+		}
+	}
+	return owned, nil
+}
+
+// CreateToken - Create a token of a user, the actor when empty, returning it along with the raw token
+// which is never stored. Only administrators create tokens for others and nobody grants scopes they lack.
+func CreateToken(actor *Principal, user, name string, scopes []Scope, expires time.Time) (*Token, string, error) {
+	if user == "" {
+		user = actor.User
+	}
+
+	if name == "" || user == "" || len(scopes) == 0 {
+		return nil, "", fmt.Errorf("a token needs a user, a name and scopes: %w", repository.InvalidArgumentError)
+	}
+
+	if user != actor.User && !actor.Can(Admin) {
+		return nil, "", fmt.Errorf("unable to create tokens of %s: %w", user, ForbiddenError)
+	}
+
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return nil, "", fmt.Errorf("unknown scope %q: %w", scope, repository.InvalidArgumentError)
+		}
+		if !actor.Can(scope) {
+			return nil, "", fmt.Errorf("unable to grant the %s scope: %w", scope, ForbiddenError)
+		}
+	}
+
+	if _, err := GetUser(user); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	raw := tokenPrefix + secret
+	token := &Token{
+		Id:      id,
+		User:    user,
+		Name:    name,
+		Hash:    hashToken(raw),
+		Scopes:  scopes,
+		Created: time.Now().UTC(),
+		Expires: expires,
+	}
+	err = tokenStore.update(func(items []*Token) ([]*Token, error) {
+		return append(items, token), nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	log.Printf("Token %s (%s) created for %s", id, name, user)
+	return token, raw, nil
+}
+
+// DeleteToken - Revoke a token of the actor, or of anyone for administrators
+func DeleteToken(actor *Principal, id string) error {
+	err := tokenStore.update(func(items []*Token) ([]*Token, error) {
+		for i, token := range items {
+			if token.Id != id {
+				continue
+			}
+			// tokens of others are not even acknowledged
+			if token.User != actor.User && !actor.Can(Admin) {
+				break
+			}
+			return append(items[:i], items[i+1:]...), nil
+		}
+		return nil, repository.NotFoundError
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Token %s deleted", id)
+	return nil
+}
+
+// deleteUserTokens - Revoke every token of a user
+func deleteUserTokens(user string) error {
+	return tokenStore.update(func(items []*Token) ([]*Token, error) {
+		remaining := items[:0]
+		for _, token := range items {
+			if token.User != user {
+				remaining = append(remaining, token)
+			}
+		}
+		return remaining, nil
+	})
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("unable to generate token: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
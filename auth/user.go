@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"com/gitlab/gituim/repository"
+)
+
+type User struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+type Team struct {
+	Name    string    `json:"name"`
+	Members []string  `json:"members"`
+	Created time.Time `json:"created"`
+}
+
+var (
+	userStore = &jsonStore[User]{name: "users.json"}
+	teamStore = &jsonStore[Team]{name: "teams.json"}
+
+	// accountName - Names of users and teams
+	accountName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+)
+
+// ListUsers - Every user
+func ListUsers() ([]*User, error) {
+	return userStore.list()
+}
+
+// GetUser - Lookup a user by name
+func GetUser(name string) (*User, error) {
+	users, err := userStore.list()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return nil, repository.NotFoundError
+}
+
+// CreateUser - Create a user, who gets access through tokens and roles
+func CreateUser(name string) (*User, error) {
+	if !accountName.MatchString(name) {
+		return nil, fmt.Errorf("invalid user name %q: %w", name, repository.InvalidArgumentError)
+	}
+
+	user := &User{Name: name, Created: time.Now().UTC()}
+	err := userStore.update(func(items []*User) ([]*User, error) {
+		for _, existing := range items {
+			if existing.Name == name {
+				return nil, fmt.Errorf("user %s: %w", name, repository.AlreadyExistsError)
+			}
+		}
+		return append(items, user), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s created", name)
+	return user, nil
+}
+
+// DeleteUser - Delete a user along with their tokens, team memberships, roles and push allowances.
+// The account goes first so that nothing new is granted to it while the rest is cleaned up.
+func DeleteUser(name string) error {
+	err := userStore.update(func(items []*User) ([]*User, error) {
+		for i, user := range items {
+			if user.Name == name {
+				return append(items[:i], items[i+1:]...), nil
+			}
+		}
+		return nil, repository.NotFoundError
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = deleteUserTokens(name); err != nil {
+		return err
+	}
+
+	err = teamStore.update(func(items []*Team) ([]*Team, error) {
+		for i, team := range items {
+			items[i] = team.withoutMember(name)
+		}
+		return items, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = repository.RevokeUser(name); err != nil {
+		return err
+	}
+
+	log.Printf("User %s deleted", name)
+	return nil
+}
+
+// ListTeams - Every team
+func ListTeams() ([]*Team, error) {
+	return teamStore.list()
+}
+
+// GetTeam - Lookup a team by name
+func GetTeam(name string) (*Team, error) {
+	teams, err := teamStore.list()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range teams {
+		if team.Name == name {
+			return team, nil
+		}
+	}
+	return nil, repository.NotFoundError
+}
+
+// CreateTeam - Create a team without members
+func CreateTeam(name string) (*Team, error) {
+	if !accountName.MatchString(name) {
+		return nil, fmt.Errorf("invalid team name %q: %w", name, repository.InvalidArgumentError)
+	}
+
+	team := &Team{Name: name, Members: []string{}, Created: time.Now().UTC()}
+	err := teamStore.update(func(items []*Team) ([]*Team, error) {
+		for _, existing := range items {
+			if existing.Name == name {
+				return nil, fmt.Errorf("team %s: %w", name, repository.AlreadyExistsError)
+			}
+		}
+		return append(items, team), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Team %s created", name)
+	return team, nil
+}
+
+// DeleteTeam - Delete a team, its members losing the roles and push allowances granted to it
+func DeleteTeam(name string) error {
+	err := teamStore.update(func(items []*Team) ([]*Team, error) {
+		for i, team := range items {
+			if team.Name == name {
+				return append(items[:i], items[i+1:]...), nil
+			}
+		}
+		return nil, repository.NotFoundError
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = repository.RevokeTeam(name); err != nil {
+		return err
+	}
+
+	log.Printf("Team %s deleted", name)
+	return nil
+}
+
+// AddTeamMember - Add a user to a team, doing nothing when they already are a member
+func AddTeamMember(name, user string) (*Team, error) {
+	if _, err := GetUser(user); err != nil {
+		return nil, err
+	}
+
+	return updateTeam(name, func(team *Team) *Team {
+		if team.hasMember(user) {
+			return team
+		}
+
+		updated := team.withoutMember(user)
+		updated.Members = append(updated.Members, user)
+		return updated
+	})
+}
+
+// RemoveTeamMember - Remove a user from a team
+func RemoveTeamMember(name, user string) (*Team, error) {
+	return updateTeam(name, func(team *Team) *Team {
+		return team.withoutMember(user)
+	})
+}
+
+// TeamsOf - Names of the teams a user is a member of
+func TeamsOf(user string) ([]string, error) {
+	teams, err := teamStore.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, team := range teams {
+		if team.hasMember(user) {
+			names = append(names, team.Name)
+		}
+	}
+	return names, nil
+}
+
+func updateTeam(name string, change func(team *Team) *Team) (*Team, error) {
+	var updated *Team
+	err := teamStore.update(func(items []*Team) ([]*Team, error) {
+		for i, team := range items {
+			if team.Name == name {
+				updated = change(team)
+				items[i] = updated
+				return items, nil
+			}
+		}
+		return nil, repository.NotFoundError
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Members of team %s updated", name)
+	return updated, nil
+}
+
+func (t *Team) hasMember(user string) bool {
+	for _, member := range t.Members {
+		if member == user {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutMember - Copy of the team without user, teams in the store are never modified in place
+func (t *Team) withoutMember(user string) *Team {
+	copied := *t
+	copied.Members = make([]string, 0, len(t.Members))
+	for _, member := range t.Members {
+		if member != user {
+			copied.Members = append(copied.Members, member)
+		}
+	}
+	return &copied
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"com/gitlab/gituim/repository"
+	"slices"
+	"testing"
+)
+
+func TestDeleteAccountsRevokeGrants(t *testing.T) {
+	for _, name := range []string{"revoked-user", "kept-user"} {
+		if _, err := CreateUser(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := CreateTeam("revoked-team"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTeamMember("revoked-team", "kept-user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repository.CreateRepository("revoked", "revoked-user"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.SetRepositoryTeamRole("revoked", "revoked-team", repository.RoleWrite); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repository.SetBranchProtection("revoked", &repository.BranchProtection{
+		Pattern: "main",
+		Users:   []string{"revoked-user"},
+		Teams:   []string{"revoked-team"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = DeleteUser("revoked-user"); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteTeam("revoked-team"); err != nil {
+		t.Fatal(err)
+	}
+
+	access, err := repository.GetRepositoryAccess("revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(access.Users) != 0 || len(access.Teams) != 0 {
+		t.Errorf("expected every grant to be revoked, got users %v and teams %v", access.Users, access.Teams)
+	}
+
+	protection, err := repository.GetBranchProtection("revoked", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(protection.Users, "revoked-user") || slices.Contains(protection.Teams, "revoked-team") {
+		t.Errorf("expected the allowances to be revoked, got %+v", protection)
+	}
+	if !protection.Restricted {
+		t.Error("expected the emptied rule to stay restricted")
+	}
+
+	// a new account under the same name starts with nothing
+	if _, err = CreateUser("revoked-user"); err != nil {
+		t.Fatal(err)
+	}
+	role, err := RoleFor(&Principal{User: "revoked-user", Scopes: []Scope{RepoWrite}}, "revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != "" {
+		t.Errorf("expected no role for the new account, got %q", role)
+	}
+}
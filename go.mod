@@ -2,10 +2,12 @@ module com/gitlab/gituim
 
 go 1.21
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/libgit2/git2go/v34 v34.0.0
+)
 
 require (
-	github.com/libgit2/git2go/v34 v34.0.0 // indirect
 	golang.org/x/crypto v0.14.0 // indirect
 	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,293 @@
+package index
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"com/gitlab/gituim/repository"
+)
+
+// trigram - Three consecutive bytes of lower cased content packed in an integer
+type trigram uint32
+
+// location - Place where a blob is found in the indexed trees
+type location struct {
+	repository string
+	path       string
+}
+
+// document - Indexed blob, shared by every location it has
+type document struct {
+	oid       string
+	trigrams  []trigram
+	locations map[location]struct{}
+}
+
+// indexedRepository - Files of the default branch of a repository when it was last indexed
+type indexedRepository struct {
+	tree  string
+	files map[string]uint32
+}
+
+// Index - In memory trigram index over the default branch of every repository.
+// Blobs are indexed once however many repositories and paths share them.
+type Index struct {
+	mutex        sync.RWMutex
+	repositories map[string]*indexedRepository
+	documentIds  map[string]uint32
+	documents    map[uint32]*document
+	postings     map[trigram]map[uint32]struct{}
+	nextId       uint32
+
+	pendingMutex sync.Mutex
+	pending      map[string]bool
+	wake         chan struct{}
+}
+
+func NewIndex() *Index {
+	return &Index{
+		repositories: map[string]*indexedRepository{},
+		documentIds:  map[string]uint32{},
+		documents:    map[uint32]*document{},
+		postings:     map[trigram]map[uint32]struct{}{},
+		pending:      map[string]bool{},
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start - Index every repository in the background, then keep up with reference updates.
+// Every interval all repositories are checked again to catch changes made outside gituim.
+func (i *Index) Start(interval time.Duration) {
+	repository.Subscribe(func(event repository.Event) {
+		i.schedule(event.Repository)
+		if event.Previous != "" {
+			i.schedule(event.Previous)
+		}
+	})
+
+	go i.run(interval)
+}
+
+func (i *Index) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	i.scheduleAll()
+	for {
+		for name, ok := i.next(); ok; name, ok = i.next() {
+			if err := i.update(name); err != nil {
+				log.Printf("unable to index %s: %v", name, err)
+			}
+		}
+
+		select {
+		case <-i.wake:
+		case <-ticker.C:
+			i.scheduleAll()
+		}
+	}
+}
+
+// schedule - Queue a repository to be indexed again, at most once however many times it moved
+func (i *Index) schedule(repositoryName string) {
+	i.pendingMutex.Lock()
+	i.pending[repositoryName] = true
+	i.pendingMutex.Unlock()
+
+	select {
+	case i.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleAll - Queue every repository, the ones indexed but gone included so they get dropped
+func (i *Index) scheduleAll() {
+	names, err := repository.ListRepositories()
+	if err != nil {
+		log.Printf("unable to list repositories to index: %v", err)
+		return
+	}
+
+	i.mutex.RLock()
+	for name := range i.repositories {
+		names = append(names, name)
+	}
+	i.mutex.RUnlock()
+
+	for _, name := range names {
+		i.schedule(name)
+	}
+}
+
+func (i *Index) next() (string, bool) {
+	i.pendingMutex.Lock()
+	defer i.pendingMutex.Unlock()
+
+	for name := range i.pending {
+		delete(i.pending, name)
+		return name, true
+	}
+	return "", false
+}
+
+// update - Bring the index of a repository up to date with its default branch.
+// Only blobs that are not indexed yet are read, so small pushes are cheap to index.
+func (i *Index) update(repositoryName string) error {
+	tree, err := repository.LookupTreeId(repositoryName, "")
+	if errors.Is(err, repository.NotFoundError) {
+		// deleted repositories and empty ones have nothing to search
+		i.replace(repositoryName, "", nil, nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	i.mutex.RLock()
+	current := i.repositories[repositoryName]
+	i.mutex.RUnlock()
+	if current != nil && current.tree == tree.String() {
+		return nil
+	}
+
+	files := map[string]string{}
+	added := map[string][]trigram{}
+	walked, err := repository.WalkFiles(repositoryName, tree.String(), func(file *repository.File) (bool, error) {
+		oid := file.Oid.String()
+		files[file.Path] = oid
+
+		if _, ok := added[oid]; ok || i.has(oid) {
+			return true, nil
+		}
+
+		contents, err := file.Read()
+		if err != nil {
+			return false, err
+		}
+		added[oid] = extractTrigrams(contents)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	i.replace(repositoryName, walked.String(), files, added)
+	log.Printf("Indexed %d files of %s at %s, %d new blobs", len(files), repositoryName, walked, len(added))
+	return nil
+}
+
+func (i *Index) has(oid string) bool {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	_, ok := i.documentIds[oid]
+	return ok
+}
+
+// replace - Swap the indexed files of a repository, files mapping paths to blob oids.
+// Blobs missing from the index are added with their trigrams, the ones left without locations are dropped.
+// An empty tree removes the repository from the index.
+func (i *Index) replace(repositoryName, tree string, files map[string]string, trigrams map[string][]trigram) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	previous := i.repositories[repositoryName]
+	if previous != nil {
+		for path, id := range previous.files {
+			i.removeLocation(id, location{repository: repositoryName, path: path})
+		}
+	}
+
+	if tree == "" {
+		delete(i.repositories, repositoryName)
+		return
+	}
+
+	indexed := &indexedRepository{tree: tree, files: make(map[string]uint32, len(files))}
+	for path, oid := range files {
+		id, ok := i.documentIds[oid]
+		if !ok {
+			id = i.addDocument(oid, trigrams[oid])
+		}
+
+		i.documents[id].locations[location{repository: repositoryName, path: path}] = struct{}{}
+		indexed.files[path] = id
+	}
+	i.repositories[repositoryName] = indexed
+
+	// locations were all removed first, clean up the documents nobody uses anymore
+	if previous != nil {
+		for _, id := range previous.files {
+			if document, ok := i.documents[id]; ok && len(document.locations) == 0 {
+				i.removeDocument(id)
+			}
+		}
+	}
+}
+
+func (i *Index) addDocument(oid string, trigrams []trigram) uint32 {
+	i.nextId++
+	id := i.nextId
+
+	i.documentIds[oid] = id
+	i.documents[id] = &document{oid: oid, trigrams: trigrams, locations: map[location]struct{}{}}
+	for _, t := range trigrams {
+		posting, ok := i.postings[t]
+		if !ok {
+			posting = map[uint32]struct{}{}
+			i.postings[t] = posting
+		}
+		posting[id] = struct{}{}
+	}
+	return id
+}
+
+func (i *Index) removeLocation(id uint32, at location) {
+	if document, ok := i.documents[id]; ok {
+		delete(document.locations, at)
+	}
+}
+
+func (i *Index) removeDocument(id uint32) {
+	document := i.documents[id]
+	for _, t := range document.trigrams {
+		delete(i.postings[t], id)
+		if len(i.postings[t]) == 0 {
+			delete(i.postings, t)
+		}
+	}
+
+	delete(i.documentIds, document.oid)
+	delete(i.documents, id)
+}
+
+// extractTrigrams - Distinct trigrams of the ASCII lower cased contents
+func extractTrigrams(contents []byte) []trigram {
+	if len(contents) < 3 {
+		return nil
+	}
+
+	seen := map[trigram]struct{}{}
+	for j := 0; j+3 <= len(contents); j++ {
+		seen[makeTrigram(contents[j], contents[j+1], contents[j+2])] = struct{}{}
+	}
+
+	trigrams := make([]trigram, 0, len(seen))
+	for t := range seen {
+		trigrams = append(trigrams, t)
+	}
+	return trigrams
+}
+
+func makeTrigram(a, b, c byte) trigram {
+	return trigram(lower(a))<<16 | trigram(lower(b))<<8 | trigram(lower(c))
+}
+
+func lower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
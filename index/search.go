@@ -0,0 +1,281 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"com/gitlab/gituim/repository"
+)
+
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 500
+
+	// maxFileMatches - Matches reported per file, they still all count for ranking
+	maxFileMatches = 20
+	// maxCandidates - Blobs read to verify a query, bounding the cost of queries with common trigrams
+	maxCandidates = 10000
+)
+
+type SearchOptions struct {
+	Query         string
+	Regex         bool
+	CaseSensitive bool
+	Repositories  []string
+	Paths         []string
+	Context       int
+	Limit         int
+}
+
+type SearchResult struct {
+	Repository string
+	Path       string
+	Oid        string
+	Score      float64
+	Matches    []*repository.SearchMatch
+}
+
+type SearchResults struct {
+	Results   []*SearchResult
+	Total     int
+	Truncated bool
+}
+
+// candidate - Blob holding every trigram of a query, with its locations at query time
+type candidate struct {
+	oid       string
+	locations []location
+}
+
+// Search - Search the default branch of every indexed repository, best results first.
+// The index only narrows down the blobs to read, every match is verified against the blob contents.
+func (i *Index) Search(options SearchOptions) (*SearchResults, error) {
+	pattern, err := repository.CompileSearchPattern(options.Query, options.Regex, options.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, glob := range options.Paths {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid path glob %q: %w", glob, repository.InvalidArgumentError)
+		}
+	}
+
+	literals := []string{options.Query}
+	if options.Regex {
+		literals = requiredLiterals(options.Query)
+	}
+
+	limit := min(options.Limit, MaxSearchLimit)
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	context := min(options.Context, repository.MaxSearchContext)
+
+	// without a trigram the index narrows nothing down and every blob would be read
+	trigrams := queryTrigrams(literals)
+	if len(trigrams) == 0 {
+		return nil, fmt.Errorf("query needs 3 consecutive ASCII characters to search for: %w", repository.InvalidArgumentError)
+	}
+
+	candidates, truncated := i.candidates(trigrams, options)
+
+	results := &SearchResults{Results: []*SearchResult{}, Truncated: truncated}
+	for _, candidate := range candidates {
+		// any location will do, they all hold the same blob
+		blob, err := repository.LookupBlob(candidate.locations[0].repository, candidate.oid)
+		if errors.Is(err, repository.NotFoundError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blob.IsBinary {
+			continue
+		}
+
+		matches, more := repository.SearchLines(blob.Contents, pattern, context, maxFileMatches)
+		if len(matches) == 0 {
+			continue
+		}
+
+		count := len(matches)
+		if more {
+			count = len(pattern.FindAllIndex(blob.Contents, -1))
+		}
+
+		for _, at := range candidate.locations {
+			results.Results = append(results.Results, &SearchResult{
+				Repository: at.repository,
+				Path:       at.path,
+				Oid:        candidate.oid,
+				Score:      score(pattern, at, count),
+				Matches:    matches,
+			})
+		}
+	}
+
+	sort.Slice(results.Results, func(a, b int) bool {
+		left, right := results.Results[a], results.Results[b]
+		if left.Score != right.Score {
+			return left.Score > right.Score
+		}
+		if left.Repository != right.Repository {
+			return left.Repository < right.Repository
+		}
+		return left.Path < right.Path
+	})
+
+	results.Total = len(results.Results)
+	if len(results.Results) > limit {
+		results.Results = results.Results[:limit]
+		results.Truncated = true
+	}
+
+	return results, nil
+}
+
+// candidates - Blobs holding every trigram, at least one, at locations matching the filters
+func (i *Index) candidates(trigrams []trigram, options SearchOptions) ([]*candidate, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	// intersect starting from the rarest trigram
+	sort.Slice(trigrams, func(a, b int) bool {
+		return len(i.postings[trigrams[a]]) < len(i.postings[trigrams[b]])
+	})
+
+	var ids []uint32
+	for id := range i.postings[trigrams[0]] {
+		matching := true
+		for _, t := range trigrams[1:] {
+			if _, ok := i.postings[t][id]; !ok {
+				matching = false
+				break
+			}
+		}
+		if matching {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(a, b int) bool {
+		return ids[a] < ids[b]
+	})
+
+	var candidates []*candidate
+	for _, id := range ids {
+		if len(candidates) == maxCandidates {
+			return candidates, true
+		}
+
+		document := i.documents[id]
+		var locations []location
+		for at := range document.locations {
+			if matchesRepositories(at.repository, options.Repositories) && repository.MatchesPathGlobs(at.path, options.Paths) {
+				locations = append(locations, at)
+			}
+		}
+
+		if len(locations) > 0 {
+			candidates = append(candidates, &candidate{oid: document.oid, locations: locations})
+		}
+	}
+
+	return candidates, false
+}
+
+func matchesRepositories(repositoryName string, repositories []string) bool {
+	if len(repositories) == 0 {
+		return true
+	}
+
+	for _, name := range repositories {
+		if name == repositoryName {
+			return true
+		}
+	}
+	return false
+}
+
+// score - Rank of a file, growing with its number of matches and favouring matches in the file name
+func score(pattern *regexp.Regexp, at location, count int) float64 {
+	value := float64(min(count, maxFileMatches))
+	switch {
+	case pattern.MatchString(path.Base(at.path)):
+		value += 2 * maxFileMatches
+	case pattern.MatchString(at.path):
+		value += maxFileMatches
+	}
+
+	// shallow files are usually the more relevant ones
+	return value / float64(1+strings.Count(at.path, "/"))
+}
+
+// queryTrigrams - Trigrams every matching blob must hold.
+// Only ASCII ones are kept, the index does not fold the case of other characters.
+func queryTrigrams(literals []string) []trigram {
+	seen := map[trigram]struct{}{}
+	for _, literal := range literals {
+		for j := 0; j+3 <= len(literal); j++ {
+			if literal[j] >= utf8.RuneSelf || literal[j+1] >= utf8.RuneSelf || literal[j+2] >= utf8.RuneSelf {
+				continue
+			}
+			seen[makeTrigram(literal[j], literal[j+1], literal[j+2])] = struct{}{}
+		}
+	}
+
+	trigrams := make([]trigram, 0, len(seen))
+	for t := range seen {
+		trigrams = append(trigrams, t)
+	}
+	return trigrams
+}
+
+// requiredLiterals - Literal strings every match of a regular expression contains
+func requiredLiterals(query string) []string {
+	parsed, err := syntax.Parse(query, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return collectLiterals(parsed.Simplify())
+}
+
+func collectLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return collectLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return collectLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		var run strings.Builder
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run.WriteString(string(sub.Rune))
+				continue
+			}
+
+			if run.Len() > 0 {
+				literals = append(literals, run.String())
+				run.Reset()
+			}
+			literals = append(literals, collectLiterals(sub)...)
+		}
+		if run.Len() > 0 {
+			literals = append(literals, run.String())
+		}
+		return literals
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package index
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"com/gitlab/gituim/repository"
+)
+
+// TestMain - Keep the indexed repositories in a temporary storage root
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "gituim-index-")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repository.GRepositoryPrefix = root
+	repository.GDataDirectory = filepath.Join(root, ".gituim")
+
+	code := m.Run()
+	os.RemoveAll(root)
+	os.Exit(code)
+}
+
+// createIndexedRepository - Repository with files on its default branch, indexed in a new index
+func createIndexedRepository(t *testing.T, repositoryName string, files map[string]string) *Index {
+	t.Helper()
+
+	if _, err := repository.CreateRepository(repositoryName, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []repository.FileChange
+	for path, content := range files {
+		changes = append(changes, repository.FileChange{Action: repository.FileCreate, Path: path, Content: []byte(content)})
+	}
+	_, err := repository.CreateCommit(repositoryName, repository.CommitOptions{
+		Branch:  "main",
+		Author:  repository.NewSignature("Test", "test@example.com", time.Now()),
+		Message: "add files\n",
+		Changes: changes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branch := "main"
+	if _, err = repository.UpdateRepository(repositoryName, repository.RepositoryUpdate{DefaultBranch: &branch}); err != nil {
+		t.Fatal(err)
+	}
+
+	index := NewIndex()
+	if err = index.update(repositoryName); err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+func TestSearch(t *testing.T) {
+	index := createIndexedRepository(t, "searched", map[string]string{
+		"main.go":        "package main\n\nfunc handler() {}\n",
+		"docs/README.md": "The handler serves requests\n",
+		"other.txt":      "nothing to see\n",
+	})
+
+	results, err := index.Search(SearchOptions{Query: "handler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 2 || results.Truncated {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	// shallow files rank first
+	if results.Results[0].Path != "main.go" || results.Results[1].Path != "docs/README.md" {
+		t.Errorf("unexpected ranking %s, %s", results.Results[0].Path, results.Results[1].Path)
+	}
+
+	results, err = index.Search(SearchOptions{Query: `func \w+\(`, Regex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 1 || results.Results[0].Path != "main.go" {
+		t.Errorf("expected the regular expression to match main.go, got %+v", results)
+	}
+}
+
+func TestSearchRequiresTrigram(t *testing.T) {
+	index := createIndexedRepository(t, "short", map[string]string{"README": "ab\n"})
+
+	for _, options := range []SearchOptions{
+		{Query: "ab"},
+		{Query: "é"},
+		{Query: `a.b`, Regex: true},
+		{Query: `.*`, Regex: true},
+	} {
+		if _, err := index.Search(options); !errors.Is(err, repository.InvalidArgumentError) {
+			t.Errorf("%q: expected InvalidArgumentError, got %v", options.Query, err)
+		}
+	}
+}
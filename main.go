@@ -1,7 +1,16 @@
 package main
 
-import "com/gitlab/gituim/api"
+import (
+	"com/gitlab/gituim/api"
+	"com/gitlab/gituim/repository"
+	"os"
+)
 
 func main() {
+	// git runs gituim again as the hook of the pushes it serves
+	if len(os.Args) > 2 && os.Args[1] == "hook" {
+		os.Exit(repository.RunHook(os.Args[2], os.Stdin, os.Stderr))
+	}
+
 	api.InitializeServer()
 }
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Role - Access level on a repository, each one including the ones before it
+type Role string
+
+const (
+	RoleRead     Role = "read"
+	RoleWrite    Role = "write"
+	RoleMaintain Role = "maintain"
+	RoleAdmin    Role = "admin"
+)
+
+type Visibility string
+
+const (
+	Private Visibility = "private"
+	Public  Visibility = "public"
+)
+
+// RepositoryAccess - Who can access a repository, users and teams by name
+type RepositoryAccess struct {
+	Visibility Visibility      `json:"visibility"`
+	Users      map[string]Role `json:"users"`
+	Teams      map[string]Role `json:"teams"`
+}
+
+// accessMutex - Serializes the read-modify-write cycles of access files
+var accessMutex sync.Mutex
+
+var roleLevels = map[Role]int{RoleRead: 1, RoleWrite: 2, RoleMaintain: 3, RoleAdmin: 4}
+
+// IsValid - Report whether the role is one gituim knows
+func (r Role) IsValid() bool {
+	return roleLevels[r] > 0
+}
+
+// Includes - Report whether holding r grants role, the empty role granting nothing
+func (r Role) Includes(role Role) bool {
+	return r != "" && roleLevels[r] >= roleLevels[role]
+}
+
+// Max - Highest of two roles
+func (r Role) Max(role Role) Role {
+	if roleLevels[role] > roleLevels[r] {
+		return role
+	}
+	return r
+}
+
+// IsValid - Report whether the visibility is one gituim knows
+func (v Visibility) IsValid() bool {
+	return v == Private || v == Public
+}
+
+// GetRepositoryAccess - Access rules of a repository, private to everyone when none were set
+func GetRepositoryAccess(repositoryName string) (*RepositoryAccess, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := openRepository(name); err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	accessMutex.Lock()
+	defer accessMutex.Unlock()
+
+	return readAccess(name)
+}
+
+// SetRepositoryVisibility - Make a repository public, readable by anyone, or private
+func SetRepositoryVisibility(repositoryName string, visibility Visibility) (*RepositoryAccess, error) {
+	if !visibility.IsValid() {
+		return nil, fmt.Errorf("unknown visibility %q: %w", visibility, InvalidArgumentError)
+	}
+
+	return updateAccess(repositoryName, func(access *RepositoryAccess) {
+		access.Visibility = visibility
+	})
+}
+
+// SetRepositoryUserRole - Grant a role on a repository to a user, the empty role revoking it
+func SetRepositoryUserRole(repositoryName, user string, role Role) (*RepositoryAccess, error) {
+	if role != "" && !role.IsValid() {
+		return nil, fmt.Errorf("unknown role %q: %w", role, InvalidArgumentError)
+	}
+
+	return updateAccess(repositoryName, func(access *RepositoryAccess) {
+		setRole(access.Users, user, role)
+	})
+}
+
+// SetRepositoryTeamRole - Grant a role on a repository to every member of a team, the empty role revoking it
+func SetRepositoryTeamRole(repositoryName, team string, role Role) (*RepositoryAccess, error) {
+	if role != "" && !role.IsValid() {
+		return nil, fmt.Errorf("unknown role %q: %w", role, InvalidArgumentError)
+	}
+
+	return updateAccess(repositoryName, func(access *RepositoryAccess) {
+		setRole(access.Teams, team, role)
+	})
+}
+
+// RevokeUser - Remove the roles and push allowances of a user from every repository, so that an
+// account created later under the same name inherits none of them
+func RevokeUser(user string) error {
+	return revokeAccount(user, false)
+}
+
+// RevokeTeam - Remove the roles and push allowances of a team from every repository
+func RevokeTeam(team string) error {
+	return revokeAccount(team, true)
+}
+
+func revokeAccount(account string, team bool) error {
+	repositories, err := ListRepositories()
+	if err != nil {
+		return err
+	}
+
+	for _, repositoryName := range repositories {
+		err = revokeRole(repositoryName, account, team)
+		if err == nil {
+			err = revokeProtections(repositoryName, account, team)
+		}
+		// repositories deleted since they were listed have nothing left to revoke
+		if err != nil && !errors.Is(err, NotFoundError) {
+			return fmt.Errorf("unable to revoke %s on %s: %w", account, repositoryName, err)
+		}
+	}
+	return nil
+}
+
+// revokeRole - Remove the role of a user, or a team, on a repository
+func revokeRole(repositoryName, account string, team bool) error {
+	access, err := GetRepositoryAccess(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	if team {
+		if _, ok := access.Teams[account]; ok {
+			_, err = SetRepositoryTeamRole(repositoryName, account, "")
+		}
+	} else if _, ok := access.Users[account]; ok {
+		_, err = SetRepositoryUserRole(repositoryName, account, "")
+	}
+	return err
+}
+
+func setRole(roles map[string]Role, name string, role Role) {
+	if role == "" {
+		delete(roles, name)
+	} else {
+		roles[name] = role
+	}
+}
+
+func updateAccess(repositoryName string, change func(access *RepositoryAccess)) (*RepositoryAccess, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := openRepository(name); err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	accessMutex.Lock()
+	defer accessMutex.Unlock()
+
+	access, err := readAccess(name)
+	if err != nil {
+		return nil, err
+	}
+
+	change(access)
+	if err = writeJSONFile(name.dataPath("access.json"), access); err != nil {
+		return nil, fmt.Errorf("unable to save access rules: %w", err)
+	}
+
+	log.Printf("Access rules of %s updated", repositoryName)
+	return access, nil
+}
+
+func readAccess(name RepositoryName) (*RepositoryAccess, error) {
+	access := &RepositoryAccess{}
+	if _, err := readJSONFile(name.dataPath("access.json"), access); err != nil {
+		return nil, fmt.Errorf("unable to read access rules: %w", err)
+	}
+
+	if access.Visibility == "" {
+		access.Visibility = Private
+	}
+	if access.Users == nil {
+		access.Users = map[string]Role{}
+	}
+	if access.Teams == nil {
+		access.Teams = map[string]Role{}
+	}
+	return access, nil
+}
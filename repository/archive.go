@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveTar   ArchiveFormat = "tar"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// archiveWriter - Common interface over tar and zip writers
+type archiveWriter interface {
+	writeDir(name string, modified time.Time) error
+	writeFile(name string, mode git.Filemode, contents []byte, modified time.Time) error
+	Close() error
+}
+
+// WriteArchive - Stream the tree at path in a revision as an archive, every entry prefixed with prefix.
+// Nothing is written to w when the revision or path cannot be resolved.
+func WriteArchive(repositoryName, revision, path, prefix string, format ArchiveFormat, w io.Writer) error {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+
+	resolved, err := lookupPath(repository, revision, path)
+	if err != nil {
+		return err
+	}
+
+	if resolved.object.Type() != git.ObjectTree {
+		return NotFoundError
+	}
+
+	tree, err := resolved.object.AsTree()
+	if err != nil {
+		return handleGitError(err, "unable to get tree")
+	}
+
+	// like git archive, entries carry the commit time when there is one
+	modified := time.Now()
+	if commit, err := resolved.revision.Peel(git.ObjectCommit); err == nil {
+		if commit, err := commit.AsCommit(); err == nil {
+			modified = commit.Committer().When
+		}
+	}
+
+	var archive archiveWriter
+	switch format {
+	case ArchiveTar:
+		archive = &tarArchive{writer: tar.NewWriter(w)}
+	case ArchiveTarGz:
+		compressed := gzip.NewWriter(w)
+		archive = &tarArchive{writer: tar.NewWriter(compressed), compressed: compressed}
+	case ArchiveZip:
+		archive = &zipArchive{writer: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unsupported archive format %s", format)
+	}
+
+	if prefix != "" {
+		if err = archive.writeDir(prefix, modified); err != nil {
+			return fmt.Errorf("unable to write archive: %w", err)
+		}
+	}
+
+	err = tree.Walk(func(root string, entry *git.TreeEntry) error {
+		name := prefix + root + entry.Name
+
+		switch entry.Filemode {
+		case git.FilemodeTree, git.FilemodeCommit:
+			// submodules are archived as empty directories
+			return archive.writeDir(name+"/", modified)
+		default:
+			blob, err := repository.LookupBlob(entry.Id)
+			if err != nil {
+				return err
+			}
+			return archive.writeFile(name, entry.Filemode, blob.Contents(), modified)
+		}
+	})
+	if err != nil {
+		return handleGitError(err, "unable to write archive")
+	}
+
+	if err = archive.Close(); err != nil {
+		return fmt.Errorf("unable to close archive: %w", err)
+	}
+
+	return nil
+}
+
+type tarArchive struct {
+	writer     *tar.Writer
+	compressed *gzip.Writer
+}
+
+func (a *tarArchive) writeDir(name string, modified time.Time) error {
+	return a.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name,
+		Mode:     0755,
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	})
+}
+
+func (a *tarArchive) writeFile(name string, mode git.Filemode, contents []byte, modified time.Time) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(contents)),
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	}
+
+	switch mode {
+	case git.FilemodeBlobExecutable:
+		header.Mode = 0755
+	case git.FilemodeLink:
+		header.Typeflag = tar.TypeSymlink
+		header.Mode = 0777
+		header.Size = 0
+		header.Linkname = string(contents)
+		return a.writer.WriteHeader(header)
+	}
+
+	if err := a.writer.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err := a.writer.Write(contents)
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+
+	if a.compressed != nil {
+		return a.compressed.Close()
+	}
+	return nil
+}
+
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+func (a *zipArchive) writeDir(name string, modified time.Time) error {
+	header := &zip.FileHeader{Name: name, Modified: modified}
+	header.SetMode(os.ModeDir | 0755)
+
+	_, err := a.writer.CreateHeader(header)
+	return err
+}
+
+func (a *zipArchive) writeFile(name string, mode git.Filemode, contents []byte, modified time.Time) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+
+	switch mode {
+	case git.FilemodeBlobExecutable:
+		header.SetMode(0755)
+	case git.FilemodeLink:
+		header.SetMode(os.ModeSymlink | 0777)
+	default:
+		header.SetMode(0644)
+	}
+
+	writer, err := a.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(contents)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.writer.Close()
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// archiveEntry - File or directory read back from an archive
+type archiveEntry struct {
+	mode    int64
+	content string
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]archiveEntry {
+	t.Helper()
+
+	entries := map[string]archiveEntry{}
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[header.Name] = archiveEntry{mode: header.Mode, content: string(content)}
+	}
+}
+
+func readZip(t *testing.T, data []byte) map[string]archiveEntry {
+	t.Helper()
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := map[string]archiveEntry{}
+	for _, file := range reader.File {
+		opened, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(opened)
+		opened.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name] = archiveEntry{mode: int64(file.Mode().Perm()), content: string(content)}
+	}
+	return entries
+}
+
+func TestWriteArchive(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	executable := true
+	testCommit(t, "repo", "main", time.Time{},
+		writeFile("README", "hello", true),
+		writeFile("src/main.go", "package main", true),
+		FileChange{Action: FileCreate, Path: "run.sh", Content: []byte("#!/bin/sh"), Executable: &executable},
+	)
+
+	expected := map[string]archiveEntry{
+		"repo-main/":            {mode: 0755},
+		"repo-main/README":      {mode: 0644, content: "hello"},
+		"repo-main/run.sh":      {mode: 0755, content: "#!/bin/sh"},
+		"repo-main/src/":        {mode: 0755},
+		"repo-main/src/main.go": {mode: 0644, content: "package main"},
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveTarGz, ArchiveZip} {
+		var buffer bytes.Buffer
+		if err := WriteArchive("repo", "main", "", "repo-main/", format, &buffer); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		var entries map[string]archiveEntry
+		switch format {
+		case ArchiveTar:
+			entries = readTar(t, &buffer)
+		case ArchiveTarGz:
+			decompressed, err := gzip.NewReader(&buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries = readTar(t, decompressed)
+		case ArchiveZip:
+			entries = readZip(t, buffer.Bytes())
+		}
+
+		if len(entries) != len(expected) {
+			t.Errorf("%s: expected %d entries, got %v", format, len(expected), entries)
+		}
+		for name, entry := range expected {
+			if entries[name] != entry {
+				t.Errorf("%s: expected %s to be %+v, got %+v", format, name, entry, entries[name])
+			}
+		}
+	}
+}
+
+func TestWriteArchivePath(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("README", "hello", true), writeFile("src/main.go", "package main", true))
+
+	var buffer bytes.Buffer
+	if err := WriteArchive("repo", "main", "src", "", ArchiveTar, &buffer); err != nil {
+		t.Fatal(err)
+	}
+	entries := readTar(t, &buffer)
+	if len(entries) != 1 || entries["main.go"].content != "package main" {
+		t.Errorf("expected only the files of src, got %v", entries)
+	}
+
+	for _, path := range []string{"missing", "README"} {
+		buffer.Reset()
+		if err := WriteArchive("repo", "main", path, "", ArchiveTar, &buffer); !errors.Is(err, NotFoundError) {
+			t.Errorf("%s: expected NotFoundError, got %v", path, err)
+		}
+		if buffer.Len() != 0 {
+			t.Errorf("%s: expected nothing written, got %d bytes", path, buffer.Len())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+type BlameHunk struct {
+	Commit     *git.Oid
+	Author     *git.Signature
+	OrigPath   string
+	OrigStart  int
+	FinalStart int
+	Lines      int
+	Boundary   bool
+	Content    []string
+}
+
+type BlameOptions struct {
+	StartLine   int
+	EndLine     int
+	FirstParent bool
+}
+
+// BlameFile - Attribute the lines of the file at path in a revision to the commits that introduced them.
+// Lines moved by a rename keep their commit, the path they had there is reported as OrigPath.
+func BlameFile(repositoryName, revision, path string, options BlameOptions) ([]*BlameHunk, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	resolved, err := lookupPath(repository, revision, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolved.object.Type() != git.ObjectBlob {
+		return nil, NotFoundError
+	}
+
+	blob, err := resolved.object.AsBlob()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get blob")
+	}
+
+	if blob.IsBinary() {
+		return nil, fmt.Errorf("unable to blame binary file %s: %w", resolved.path, InvalidArgumentError)
+	}
+
+	commit, err := resolved.revision.Peel(git.ObjectCommit)
+	if err != nil {
+		return nil, fmt.Errorf("blame needs a revision resolving to a commit: %w", InvalidArgumentError)
+	}
+
+	lines := bytes.SplitAfter(blob.Contents(), []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	if options.EndLine == 0 || options.EndLine > len(lines) {
+		options.EndLine = len(lines)
+	}
+	if options.StartLine == 0 {
+		options.StartLine = 1
+	}
+	if len(lines) == 0 {
+		return []*BlameHunk{}, nil
+	}
+	if options.StartLine > options.EndLine {
+		return nil, fmt.Errorf("invalid line range %d-%d: %w", options.StartLine, options.EndLine, InvalidArgumentError)
+	}
+
+	blameOptions, err := git.DefaultBlameOptions()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get blame options")
+	}
+
+	blameOptions.NewestCommit = commit.Id()
+	blameOptions.MinLine = uint32(options.StartLine)
+	blameOptions.MaxLine = uint32(options.EndLine)
+	if options.FirstParent {
+		blameOptions.Flags |= git.BlameFirstParent
+	}
+
+	blame, err := repository.BlameFile(resolved.path, &blameOptions)
+	if err != nil {
+		return nil, handleGitError(err, "unable to blame file")
+	}
+	defer blame.Free()
+
+	hunks := make([]*BlameHunk, 0, blame.HunkCount())
+	for i := 0; i < blame.HunkCount(); i++ {
+		hunk, err := blame.HunkByIndex(i)
+		if err != nil {
+			return nil, handleGitError(err, "unable to get blame hunk")
+		}
+
+		blameHunk := &BlameHunk{
+			Commit:     hunk.FinalCommitId,
+			Author:     hunk.FinalSignature,
+			OrigPath:   hunk.OrigPath,
+			OrigStart:  int(hunk.OrigStartLineNumber),
+			FinalStart: int(hunk.FinalStartLineNumber),
+			Lines:      int(hunk.LinesInHunk),
+			Boundary:   hunk.Boundary,
+		}
+
+		for line := blameHunk.FinalStart; line < blameHunk.FinalStart+blameHunk.Lines && line <= len(lines); line++ {
+			blameHunk.Content = append(blameHunk.Content, string(lines[line-1]))
+		}
+
+		hunks = append(hunks, blameHunk)
+	}
+
+	return hunks, nil
+}
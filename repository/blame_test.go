@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlameFile(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	first := testCommit(t, "repo", "main", time.Time{}, writeFile("old.txt", "a\nb\nc\n", true))
+	testCommit(t, "repo", "main", time.Time{}, FileChange{Action: FileMove, PreviousPath: "old.txt", Path: "new.txt"})
+	last := testCommit(t, "repo", "main", time.Time{}, writeFile("new.txt", "a\nb\nc\nd\n", false))
+
+	hunks, err := BlameFile("repo", "main", "new.txt", BlameOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+
+	// the lines kept through the rename stay with the commit that wrote them
+	renamed, appended := hunks[0], hunks[1]
+	if !renamed.Commit.Equal(first.Commit) || renamed.OrigPath != "old.txt" || renamed.FinalStart != 1 || renamed.Lines != 3 ||
+		strings.Join(renamed.Content, "") != "a\nb\nc\n" {
+		t.Errorf("unexpected renamed hunk %+v", renamed)
+	}
+	if !appended.Commit.Equal(last.Commit) || appended.OrigPath != "new.txt" || appended.FinalStart != 4 || appended.Lines != 1 ||
+		strings.Join(appended.Content, "") != "d\n" || appended.Author.Email != "test@example.com" {
+		t.Errorf("unexpected appended hunk %+v", appended)
+	}
+}
+
+func TestBlameFileLineRange(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	first := testCommit(t, "repo", "main", time.Time{}, writeFile("file", "1\n2\n3\n", true), writeFile("bin", "a\x00b", true))
+	testCommit(t, "repo", "main", time.Time{}, writeFile("file", "1\n2\n3\n4\n5\n", false))
+
+	hunks, err := BlameFile("repo", "main", "file", BlameOptions{StartLine: 2, EndLine: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 || !hunks[0].Commit.Equal(first.Commit) || hunks[0].FinalStart != 2 || strings.Join(hunks[0].Content, "") != "2\n3\n" {
+		t.Errorf("expected lines 2 and 3 from the first commit, got %+v", hunks)
+	}
+
+	// an end past the file is clamped to its last line
+	hunks, err = BlameFile("repo", "main", "file", BlameOptions{StartLine: 5, EndLine: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 || hunks[0].FinalStart != 5 || strings.Join(hunks[0].Content, "") != "5\n" {
+		t.Errorf("expected the last line only, got %+v", hunks)
+	}
+
+	tests := []struct {
+		path    string
+		options BlameOptions
+		err     error
+	}{
+		{"file", BlameOptions{StartLine: 3, EndLine: 2}, InvalidArgumentError},
+		{"file", BlameOptions{StartLine: 6}, InvalidArgumentError},
+		{"bin", BlameOptions{}, InvalidArgumentError},
+		{"missing", BlameOptions{}, NotFoundError},
+	}
+	for _, test := range tests {
+		if _, err = BlameFile("repo", "main", test.path, test.options); !errors.Is(err, test.err) {
+			t.Errorf("%s %+v: expected %v, got %v", test.path, test.options, test.err, err)
+		}
+	}
+}
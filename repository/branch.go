@@ -1,14 +1,17 @@
 package repository
 
 import (
+	"fmt"
+	"log"
+
 	git "github.com/libgit2/git2go/v34"
 )
 
 type Branch struct {
-	Branch string
-	Commit *git.Oid
-	Tree   *git.Oid
-	Parent *git.Oid
+	Branch  string
+	Commit  *git.Oid
+	Tree    *git.Oid
+	Parents []*git.Oid
 }
 
 // ListRepositoryBranches - list all branches in the repository
@@ -45,7 +48,7 @@ func ListRepositoryBranches(repositoryName string) ([]Branch, error) {
 	return branches, nil
 }
 
-// GetBranch - Get branch commit, tree and parent
+// GetBranch - Get branch commit, tree and parents
 func GetBranch(repositoryName, branchName string) (*Branch, error) {
 	repository, err := openRepositoryNoSearch(repositoryName)
 	if err != nil {
@@ -67,6 +70,159 @@ func GetBranch(repositoryName, branchName string) (*Branch, error) {
 		return nil, handleGitError(err, "unable to get commit tree")
 	}
 
-	parent := commit.ParentId(0)
-	return &Branch{Branch: branchName, Commit: commit.Id(), Tree: tree.Id(), Parent: parent}, nil
+	return &Branch{Branch: branchName, Commit: commit.Id(), Tree: tree.Id(), Parents: getParentIds(commit)}, nil
+}
+
+// CreateBranch - Create a branch pointing at the commit a revision resolves to
+func CreateBranch(repositoryName, branchName, revision string, pusher *Pusher) (*Branch, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	commit, err := revparseCommit(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkReferenceUpdates(repository, repositoryName, pusher, ReferenceUpdate{Name: "refs/heads/" + branchName, New: commit.Id()})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = repository.CreateBranch(branchName, commit, false)
+	if err != nil {
+		return nil, handleGitError(err, "unable to create branch")
+	}
+
+	log.Printf("Branch %s created in %s at %s", branchName, repositoryName, commit.Id())
+	publishReferenceUpdate(repositoryName, "refs/heads/"+branchName, nil, commit.Id())
+	return GetBranch(repositoryName, branchName)
+}
+
+// UpdateBranch - Move a branch to the commit a revision resolves to.
+// When expected is set the update only happens if the branch still points at it,
+// and unless force is set the new commit must descend from the current one.
+// Protected branches may refuse forced updates regardless.
+func UpdateBranch(repositoryName, branchName, revision, expected string, force bool, pusher *Pusher) (*Branch, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	branch, err := lookupExpectedBranch(repository, branchName, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := revparseCommit(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	current := branch.Target()
+	if !force && !current.Equal(commit.Id()) {
+		descendant, err := repository.DescendantOf(commit.Id(), current)
+		if err != nil {
+			return nil, handleGitError(err, "unable to check ancestry")
+		}
+		if !descendant {
+			return nil, fmt.Errorf("%s is not a fast-forward of %s: %w", commit.Id(), branchName, ConflictError)
+		}
+	}
+
+	update := ReferenceUpdate{Name: "refs/heads/" + branchName, Old: current, New: commit.Id()}
+	if err = checkReferenceUpdates(repository, repositoryName, pusher, update); err != nil {
+		return nil, err
+	}
+
+	// libgit2 only replaces the reference if it still holds the target read above
+	_, err = branch.SetTarget(commit.Id(), fmt.Sprintf("gituim: update from %s", current))
+	if err != nil {
+		return nil, handleGitError(err, "unable to update branch")
+	}
+
+	log.Printf("Branch %s in %s moved from %s to %s", branchName, repositoryName, current, commit.Id())
+	publishReferenceUpdate(repositoryName, "refs/heads/"+branchName, current, commit.Id())
+	return GetBranch(repositoryName, branchName)
+}
+
+// RenameBranch - Rename a branch, failing if the new name is taken
+func RenameBranch(repositoryName, branchName, newName string, pusher *Pusher) (*Branch, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	branch, err := repository.LookupBranch(branchName, git.BranchLocal)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup branch")
+	}
+
+	// renaming deletes the branch under its old name
+	updates := []ReferenceUpdate{
+		{Name: "refs/heads/" + branchName, Old: branch.Target()},
+		{Name: "refs/heads/" + newName, New: branch.Target()},
+	}
+	if err = checkReferenceUpdates(repository, repositoryName, pusher, updates...); err != nil {
+		return nil, err
+	}
+
+	_, err = branch.Move(newName, false)
+	if err != nil {
+		return nil, handleGitError(err, "unable to rename branch")
+	}
+
+	log.Printf("Branch %s in %s renamed to %s", branchName, repositoryName, newName)
+	publish(Event{Type: ReferencesUpdated, Repository: repositoryName, Updates: updates})
+	return GetBranch(repositoryName, newName)
+}
+
+// DeleteBranch - Delete a branch, only if it still points at expected when set
+func DeleteBranch(repositoryName, branchName, expected string, pusher *Pusher) error {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+
+	branch, err := lookupExpectedBranch(repository, branchName, expected)
+	if err != nil {
+		return err
+	}
+
+	isHead, err := branch.IsHead()
+	if err != nil {
+		return handleGitError(err, "unable to check branch")
+	}
+	if isHead {
+		return fmt.Errorf("unable to delete the default branch %s: %w", branchName, ConflictError)
+	}
+
+	err = checkReferenceUpdates(repository, repositoryName, pusher, ReferenceUpdate{Name: "refs/heads/" + branchName, Old: branch.Target()})
+	if err != nil {
+		return err
+	}
+
+	err = branch.Delete()
+	if err != nil {
+		return handleGitError(err, "unable to delete branch")
+	}
+
+	log.Printf("Branch %s deleted from %s", branchName, repositoryName)
+	publishReferenceUpdate(repositoryName, "refs/heads/"+branchName, branch.Target(), nil)
+	return nil
+}
+
+// lookupExpectedBranch - Lookup a local branch and check it points at expected when set
+func lookupExpectedBranch(repository *git.Repository, branchName, expected string) (*git.Branch, error) {
+	branch, err := repository.LookupBranch(branchName, git.BranchLocal)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup branch")
+	}
+
+	if expected != "" && branch.Target().String() != expected {
+		return nil, fmt.Errorf("branch %s is at %s, expected %s: %w", branchName, branch.Target(), expected, ConflictError)
+	}
+
+	return branch, nil
 }
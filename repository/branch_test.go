@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreateBranch(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	first := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	testCommit(t, "repo", "main", time.Time{}, writeFile("README", "b", false))
+
+	branch, err := CreateBranch("repo", "feature", "main~1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch.Branch != "feature" || !branch.Commit.Equal(first.Commit) {
+		t.Errorf("expected feature at %s, got %+v", first.Commit, branch)
+	}
+
+	if _, err = CreateBranch("repo", "feature", "main", nil); !errors.Is(err, AlreadyExistsError) {
+		t.Errorf("expected AlreadyExistsError for an existing branch, got %v", err)
+	}
+	if _, err = CreateBranch("repo", "other", "missing", nil); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError for a missing revision, got %v", err)
+	}
+}
+
+func TestUpdateBranch(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	first := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	second := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "b", false))
+	unrelated := testCommit(t, "repo", "unrelated", time.Time{}, writeFile("OTHER", "c", true))
+	if _, err := CreateBranch("repo", "feature", first.Commit.String(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stale expectation is refused, so concurrent updates do not clobber each other
+	if _, err := UpdateBranch("repo", "feature", "main", second.Commit.String(), false, nil); !errors.Is(err, ConflictError) {
+		t.Errorf("expected ConflictError for a stale expected commit, got %v", err)
+	}
+
+	branch, err := UpdateBranch("repo", "feature", "main", first.Commit.String(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !branch.Commit.Equal(second.Commit) {
+		t.Errorf("expected the fast-forward to %s, got %s", second.Commit, branch.Commit)
+	}
+
+	if _, err = UpdateBranch("repo", "feature", "unrelated", "", false, nil); !errors.Is(err, ConflictError) {
+		t.Errorf("expected ConflictError for a non fast-forward update, got %v", err)
+	}
+
+	branch, err = UpdateBranch("repo", "feature", "unrelated", second.Commit.String(), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !branch.Commit.Equal(unrelated.Commit) {
+		t.Errorf("expected the forced update to %s, got %s", unrelated.Commit, branch.Commit)
+	}
+}
+
+func TestRenameBranch(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	commit := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	for _, name := range []string{"old", "taken"} {
+		if _, err := CreateBranch("repo", name, "main", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var updates []ReferenceUpdate
+	Subscribe(func(event Event) {
+		if event.Repository == "repo" && event.Type == ReferencesUpdated {
+			updates = append(updates, event.Updates...)
+		}
+	})
+
+	if _, err := RenameBranch("repo", "old", "taken", nil); !errors.Is(err, AlreadyExistsError) {
+		t.Errorf("expected AlreadyExistsError for a taken name, got %v", err)
+	}
+
+	branch, err := RenameBranch("repo", "old", "new", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch.Branch != "new" || !branch.Commit.Equal(commit.Commit) {
+		t.Errorf("unexpected renamed branch %+v", branch)
+	}
+	if _, err = GetBranch("repo", "old"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected the old name to be gone, got %v", err)
+	}
+
+	if len(updates) != 2 || updates[0].Name != "refs/heads/old" || updates[0].New != nil ||
+		updates[1].Name != "refs/heads/new" || updates[1].Old != nil || !updates[1].New.Equal(commit.Commit) {
+		t.Errorf("expected the deletion of old and creation of new, got %+v", updates)
+	}
+}
+
+func TestDeleteBranch(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	first := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	second := testCommit(t, "repo", "stable", time.Time{}, writeFile("README", "b", true))
+	mainBranch := "main"
+	if _, err := UpdateRepository("repo", RepositoryUpdate{DefaultBranch: &mainBranch}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteBranch("repo", "main", "", nil); !errors.Is(err, ConflictError) {
+		t.Errorf("expected ConflictError for the default branch, got %v", err)
+	}
+	if err := DeleteBranch("repo", "stable", first.Commit.String(), nil); !errors.Is(err, ConflictError) {
+		t.Errorf("expected ConflictError for a stale expected commit, got %v", err)
+	}
+
+	if err := DeleteBranch("repo", "stable", second.Commit.String(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetBranch("repo", "stable"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected the branch to be deleted, got %v", err)
+	}
+	if err := DeleteBranch("repo", "stable", "", nil); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError for a missing branch, got %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+type FileAction string
+
+const (
+	FileCreate FileAction = "create"
+	FileUpdate FileAction = "update"
+	FileDelete FileAction = "delete"
+	FileMove   FileAction = "move"
+	FileChmod  FileAction = "chmod"
+)
+
+type FileChange struct {
+	Action       FileAction
+	Path         string
+	PreviousPath string
+	Content      []byte
+	Executable   *bool
+}
+
+type CommitOptions struct {
+	Branch    string
+	Parent    string
+	Author    *git.Signature
+	Committer *git.Signature
+	Message   string
+	Changes   []FileChange
+	Pusher    *Pusher
+}
+
+// treeEdit - Pending change of a tree entry, a nil oid removes the entry
+type treeEdit struct {
+	oid  *git.Oid
+	mode git.Filemode
+}
+
+// CreateCommit - Commit file changes on top of a branch without a working tree.
+// Parent must be the current tip of the branch; when the branch does not exist it is
+// created from parent, or as a root commit when parent is empty.
+func CreateCommit(repositoryName string, options CommitOptions) (*Commit, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	if options.Message == "" || options.Author == nil || len(options.Changes) == 0 {
+		return nil, fmt.Errorf("a commit needs a message, an author and changes: %w", InvalidArgumentError)
+	}
+
+	committer := options.Committer
+	if committer == nil {
+		committer = options.Author
+	}
+
+	refName := "refs/heads/" + options.Branch
+	reference, err := repository.References.Lookup(refName)
+	if err != nil && !git.IsErrorCode(err, git.ErrorCodeNotFound) {
+		return nil, handleGitError(err, "unable to lookup branch")
+	}
+
+	var parents []*git.Commit
+	var baseTree *git.Tree
+	if reference != nil || options.Parent != "" {
+		parentId := options.Parent
+		if reference != nil {
+			if parentId != "" && reference.Target().String() != parentId {
+				return nil, fmt.Errorf("branch %s is at %s, expected %s: %w", options.Branch, reference.Target(), parentId, ConflictError)
+			}
+			parentId = reference.Target().String()
+		}
+
+		parent, err := revparseCommit(repository, parentId)
+		if err != nil {
+			return nil, err
+		}
+
+		baseTree, err = parent.Tree()
+		if err != nil {
+			return nil, handleGitError(err, "unable to get parent tree")
+		}
+		parents = append(parents, parent)
+	}
+
+	edits, err := planTreeEdits(repository, baseTree, options.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	treeId, err := buildTree(repository, baseTree, edits)
+	if err != nil {
+		return nil, err
+	}
+
+	if treeId == nil {
+		treeId, err = writeEmptyTree(repository)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tree, err := repository.LookupTree(treeId)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup tree")
+	}
+
+	commitId, err := repository.CreateCommit("", options.Author, committer, options.Message, tree, parents...)
+	if err != nil {
+		return nil, handleGitError(err, "unable to create commit")
+	}
+
+	var previous *git.Oid
+	if reference != nil {
+		previous = reference.Target()
+	}
+
+	err = checkReferenceUpdates(repository, repositoryName, options.Pusher, ReferenceUpdate{Name: refName, Old: previous, New: commitId})
+	if err != nil {
+		return nil, err
+	}
+
+	// only advance the branch if nobody moved it since it was read
+	if reference != nil {
+		_, err = reference.SetTarget(commitId, "gituim: commit")
+	} else {
+		_, err = repository.References.Create(refName, commitId, false, "gituim: commit")
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to update branch")
+	}
+
+	commit, err := repository.LookupCommit(commitId)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup commit")
+	}
+
+	log.Printf("Commit %s created on %s in %s", commitId, options.Branch, repositoryName)
+	publishReferenceUpdate(repositoryName, refName, previous, commitId)
+	return GetCommit(commit)
+}
+
+// planTreeEdits - Validate the file changes against the base tree and turn them into tree edits
+func planTreeEdits(repository *git.Repository, base *git.Tree, changes []FileChange) (map[string]*treeEdit, error) {
+	edits := map[string]*treeEdit{}
+
+	// current entry at path, taking the edits planned so far into account
+	current := func(filePath string) (*treeEdit, error) {
+		if edit, ok := edits[filePath]; ok {
+			if edit.oid == nil {
+				return nil, nil
+			}
+			return edit, nil
+		}
+		if base == nil {
+			return nil, nil
+		}
+
+		entry, err := base.EntryByPath(filePath)
+		if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, handleGitError(err, "unable to lookup tree entry")
+		}
+		if entry.Type != git.ObjectBlob {
+			return nil, fmt.Errorf("%s is not a file: %w", filePath, InvalidArgumentError)
+		}
+		return &treeEdit{oid: entry.Id, mode: entry.Filemode}, nil
+	}
+
+	// plan - Record the new entry at path, which cannot also be a directory of another new entry
+	plan := func(filePath string, edit *treeEdit) error {
+		for dir := path.Dir(filePath); dir != "."; dir = path.Dir(dir) {
+			if planned, ok := edits[dir]; ok && planned.oid != nil {
+				return fmt.Errorf("%s cannot be both a file and the directory of %s: %w", dir, filePath, InvalidArgumentError)
+			}
+		}
+		for other, planned := range edits {
+			if planned.oid != nil && strings.HasPrefix(other, filePath+"/") {
+				return fmt.Errorf("%s cannot be both a file and the directory of %s: %w", filePath, other, InvalidArgumentError)
+			}
+		}
+
+		edits[filePath] = edit
+		return nil
+	}
+
+	for _, change := range changes {
+		filePath, err := cleanFilePath(change.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		existing, err := current(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		switch change.Action {
+		case FileCreate, FileUpdate:
+			if change.Action == FileCreate && existing != nil {
+				return nil, fmt.Errorf("%s: %w", filePath, AlreadyExistsError)
+			}
+			if change.Action == FileUpdate && existing == nil {
+				return nil, fmt.Errorf("%s: %w", filePath, NotFoundError)
+			}
+
+			oid, err := repository.CreateBlobFromBuffer(change.Content)
+			if err != nil {
+				return nil, handleGitError(err, "unable to create blob")
+			}
+
+			mode := git.FilemodeBlob
+			if existing != nil {
+				mode = existing.mode
+			}
+			if err = plan(filePath, &treeEdit{oid: oid, mode: fileMode(mode, change.Executable)}); err != nil {
+				return nil, err
+			}
+		case FileDelete:
+			if existing == nil {
+				return nil, fmt.Errorf("%s: %w", filePath, NotFoundError)
+			}
+			edits[filePath] = &treeEdit{}
+		case FileMove:
+			previousPath, err := cleanFilePath(change.PreviousPath)
+			if err != nil {
+				return nil, err
+			}
+
+			previous, err := current(previousPath)
+			if err != nil {
+				return nil, err
+			}
+			if previous == nil {
+				return nil, fmt.Errorf("%s: %w", previousPath, NotFoundError)
+			}
+			if existing != nil {
+				return nil, fmt.Errorf("%s: %w", filePath, AlreadyExistsError)
+			}
+
+			oid := previous.oid
+			if change.Content != nil {
+				oid, err = repository.CreateBlobFromBuffer(change.Content)
+				if err != nil {
+					return nil, handleGitError(err, "unable to create blob")
+				}
+			}
+			edits[previousPath] = &treeEdit{}
+			if err = plan(filePath, &treeEdit{oid: oid, mode: fileMode(previous.mode, change.Executable)}); err != nil {
+				return nil, err
+			}
+		case FileChmod:
+			if existing == nil {
+				return nil, fmt.Errorf("%s: %w", filePath, NotFoundError)
+			}
+			if change.Executable == nil {
+				return nil, fmt.Errorf("chmod of %s needs the executable flag: %w", filePath, InvalidArgumentError)
+			}
+			if err = plan(filePath, &treeEdit{oid: existing.oid, mode: fileMode(existing.mode, change.Executable)}); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown action %q: %w", change.Action, InvalidArgumentError)
+		}
+	}
+
+	return edits, nil
+}
+
+// buildTree - Apply edits keyed by path to a tree, returning nil when the result is empty
+func buildTree(repository *git.Repository, base *git.Tree, edits map[string]*treeEdit) (*git.Oid, error) {
+	var builder *git.TreeBuilder
+	var err error
+	if base != nil {
+		builder, err = repository.TreeBuilderFromTree(base)
+	} else {
+		builder, err = repository.TreeBuilder()
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to create tree builder")
+	}
+	defer builder.Free()
+
+	subtrees := map[string]map[string]*treeEdit{}
+	for filePath, edit := range edits {
+		name, rest, nested := strings.Cut(filePath, "/")
+		if nested {
+			if subtrees[name] == nil {
+				subtrees[name] = map[string]*treeEdit{}
+			}
+			subtrees[name][rest] = edit
+			continue
+		}
+
+		if edit.oid == nil {
+			// entries created and removed again by the same commit never reached the base tree,
+			// and libgit2 does not tell a missing entry from any other failure
+			if base != nil && base.EntryByName(name) != nil {
+				err = builder.Remove(name)
+			}
+		} else {
+			err = builder.Insert(name, edit.oid, edit.mode)
+		}
+		if err != nil {
+			return nil, handleGitError(err, "unable to edit tree")
+		}
+	}
+
+	for name, subtreeEdits := range subtrees {
+		var subtree *git.Tree
+		if base != nil {
+			entry := base.EntryByName(name)
+			// a file removed by the same commit can be replaced by a directory
+			replaced := edits[name] != nil && edits[name].oid == nil
+			if entry != nil && entry.Type != git.ObjectTree && !replaced {
+				return nil, fmt.Errorf("%s is not a directory: %w", name, InvalidArgumentError)
+			}
+
+			if entry != nil && entry.Type == git.ObjectTree {
+				subtree, err = repository.LookupTree(entry.Id)
+				if err != nil {
+					return nil, handleGitError(err, "unable to lookup tree")
+				}
+			}
+		}
+
+		oid, err := buildTree(repository, subtree, subtreeEdits)
+		if err != nil {
+			return nil, err
+		}
+
+		if oid == nil {
+			if subtree != nil {
+				err = builder.Remove(name)
+			}
+		} else {
+			err = builder.Insert(name, oid, git.FilemodeTree)
+		}
+		if err != nil {
+			return nil, handleGitError(err, "unable to edit tree")
+		}
+	}
+
+	oid, err := builder.Write()
+	if err != nil {
+		return nil, handleGitError(err, "unable to write tree")
+	}
+
+	tree, err := repository.LookupTree(oid)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup tree")
+	}
+
+	if tree.EntryCount() == 0 {
+		return nil, nil
+	}
+	return oid, nil
+}
+
+func writeEmptyTree(repository *git.Repository) (*git.Oid, error) {
+	builder, err := repository.TreeBuilder()
+	if err != nil {
+		return nil, handleGitError(err, "unable to create tree builder")
+	}
+	defer builder.Free()
+
+	oid, err := builder.Write()
+	if err != nil {
+		return nil, handleGitError(err, "unable to write tree")
+	}
+	return oid, nil
+}
+
+// cleanFilePath - Normalize a repository relative file path, rejecting anything escaping the tree
+func cleanFilePath(filePath string) (string, error) {
+	cleaned := path.Clean(strings.Trim(filePath, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid path %q: %w", filePath, InvalidArgumentError)
+	}
+
+	for _, segment := range strings.Split(cleaned, "/") {
+		if strings.EqualFold(segment, ".git") {
+			return "", fmt.Errorf("invalid path %q: %w", filePath, InvalidArgumentError)
+		}
+	}
+
+	return cleaned, nil
+}
+
+// fileMode - Blob mode with the executable bit applied when requested
+func fileMode(mode git.Filemode, executable *bool) git.Filemode {
+	if executable == nil || mode == git.FilemodeLink {
+		return mode
+	}
+	if *executable {
+		return git.FilemodeBlobExecutable
+	}
+	return git.FilemodeBlob
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreateCommitFileDirectoryConflicts(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("README", "hello", true))
+
+	tests := []struct {
+		name    string
+		changes []FileChange
+	}{
+		{"file then nested file", []FileChange{writeFile("a", "1", true), writeFile("a/b", "2", true)}},
+		{"nested file then file", []FileChange{writeFile("a/b/c", "1", true), writeFile("a", "2", true)}},
+		{"move onto a directory", []FileChange{
+			writeFile("a/b", "1", true),
+			{Action: FileMove, PreviousPath: "README", Path: "a"},
+		}},
+		{"file over an existing file", []FileChange{writeFile("README/a", "1", true)}},
+	}
+
+	for _, test := range tests {
+		_, err := CreateCommit("repo", CommitOptions{
+			Branch:  "main",
+			Author:  NewSignature("Test", "test@example.com", time.Time{}),
+			Message: test.name,
+			Changes: test.changes,
+		})
+		if !errors.Is(err, InvalidArgumentError) {
+			t.Errorf("%s: expected InvalidArgumentError, got %v", test.name, err)
+		}
+	}
+}
+
+func TestCreateCommitReplaceFileByDirectory(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("a", "hello", true))
+
+	// the file moves into a directory taking its own name
+	testCommit(t, "repo", "main", time.Time{}, FileChange{Action: FileMove, PreviousPath: "a", Path: "a/a"})
+
+	blob, err := LookupBlobByPath("repo", "main", "a/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blob.Contents) != "hello" {
+		t.Errorf("expected the moved content, got %q", blob.Contents)
+	}
+}
+
+func TestCreateCommitMoveKeepsContent(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("old", "hello", true))
+
+	testCommit(t, "repo", "main", time.Time{}, FileChange{Action: FileMove, PreviousPath: "old", Path: "new"})
+
+	blob, err := LookupBlobByPath("repo", "main", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blob.Contents) != "hello" {
+		t.Errorf("expected the moved content, got %q", blob.Contents)
+	}
+	if _, err = LookupBlobByPath("repo", "main", "old"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected the previous path to be gone, got %v", err)
+	}
+}
+
+func TestCreateCommitChangesUndoneInSameCommit(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("a", "hello", true))
+
+	tests := []struct {
+		name    string
+		changes []FileChange
+		gone    []string
+		kept    map[string]string
+	}{
+		{"create then delete", []FileChange{
+			writeFile("b", "1", true),
+			{Action: FileDelete, Path: "b"},
+		}, []string{"b"}, map[string]string{"a": "hello"}},
+		{"nested create then delete", []FileChange{
+			writeFile("dir/b", "1", true),
+			{Action: FileDelete, Path: "dir/b"},
+		}, []string{"dir/b", "dir"}, map[string]string{"a": "hello"}},
+		{"chained moves", []FileChange{
+			{Action: FileMove, PreviousPath: "a", Path: "b"},
+			{Action: FileMove, PreviousPath: "b", Path: "c"},
+		}, []string{"a", "b"}, map[string]string{"c": "hello"}},
+	}
+
+	for _, test := range tests {
+		testCommit(t, "repo", "main", time.Time{}, test.changes...)
+
+		for _, filePath := range test.gone {
+			if _, err := LookupBlobByPath("repo", "main", filePath); !errors.Is(err, NotFoundError) {
+				t.Errorf("%s: expected %s to be gone, got %v", test.name, filePath, err)
+			}
+		}
+		for filePath, content := range test.kept {
+			blob, err := LookupBlobByPath("repo", "main", filePath)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if string(blob.Contents) != content {
+				t.Errorf("%s: expected %q at %s, got %q", test.name, content, filePath, blob.Contents)
+			}
+		}
+	}
+}
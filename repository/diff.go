@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"fmt"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+type Diff struct {
+	Base      *git.Oid
+	Head      *git.Oid
+	Files     []*DiffFile
+	Additions int
+	Deletions int
+	// Patch - Unified patch of the diff, only formatted when asked for
+	Patch []byte
+}
+
+type DiffOptions struct {
+	// Patch - Format the diff as a unified patch too
+	Patch bool
+}
+
+type DiffFile struct {
+	Status     git.Delta
+	OldPath    string
+	NewPath    string
+	OldOid     *git.Oid
+	NewOid     *git.Oid
+	OldMode    git.Filemode
+	NewMode    git.Filemode
+	Similarity int
+	IsBinary   bool
+	Additions  int
+	Deletions  int
+	Hunks      []*DiffHunk
+}
+
+type DiffHunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []*DiffLine
+}
+
+type DiffLine struct {
+	Origin    git.DiffLineType
+	OldLineno int
+	NewLineno int
+	Content   string
+}
+
+// DiffCommit - Diff a commit against one of its parents, 1 being the first parent.
+// Root commits are compared with the empty tree.
+func DiffCommit(repositoryName, commitId string, parent int, options DiffOptions) (*Diff, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	oid, err := parseOid(commitId)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repository.LookupCommit(oid)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup commit")
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get commit tree")
+	}
+
+	if commit.ParentCount() == 0 {
+		return buildDiff(repository, nil, nil, commit.Id(), tree, options)
+	}
+
+	if parent < 1 || uint(parent) > commit.ParentCount() {
+		return nil, NotFoundError
+	}
+
+	parentCommit := commit.Parent(uint(parent - 1))
+	if parentCommit == nil {
+		return nil, fmt.Errorf("unable to lookup parent %d of %s", parent, commitId)
+	}
+
+	parentTree, err := parentCommit.Tree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get parent tree")
+	}
+
+	return buildDiff(repository, parentCommit.Id(), parentTree, commit.Id(), tree, options)
+}
+
+// CompareRevisions - Diff head against its merge base with base, like `git diff base...head`
+func CompareRevisions(repositoryName, base, head string, options DiffOptions) (*Diff, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	baseCommit, err := revparseCommit(repository, base)
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := revparseCommit(repository, head)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeBase, err := repository.MergeBase(baseCommit.Id(), headCommit.Id())
+	if err != nil {
+		return nil, handleGitError(err, "unable to find merge base")
+	}
+
+	mergeBaseCommit, err := repository.LookupCommit(mergeBase)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup merge base")
+	}
+
+	mergeBaseTree, err := mergeBaseCommit.Tree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get merge base tree")
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get head tree")
+	}
+
+	return buildDiff(repository, mergeBase, mergeBaseTree, headCommit.Id(), headTree, options)
+}
+
+// revparseCommit - Resolve a revision and peel it to a commit
+func revparseCommit(repository *git.Repository, revision string) (*git.Commit, error) {
+	object, err := repository.RevparseSingle(revision)
+	if err != nil {
+		return nil, handleGitError(err, "unable to rev parse revision")
+	}
+
+	peeled, err := object.Peel(git.ObjectCommit)
+	if err != nil {
+		return nil, handleGitError(err, "unable to peel revision to a commit")
+	}
+
+	commit, err := peeled.AsCommit()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get commit")
+	}
+
+	return commit, nil
+}
+
+func buildDiff(repository *git.Repository, base *git.Oid, baseTree *git.Tree, head *git.Oid, headTree *git.Tree, diffOptions DiffOptions) (*Diff, error) {
+	options, err := git.DefaultDiffOptions()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get diff options")
+	}
+
+	diff, err := repository.DiffTreeToTree(baseTree, headTree, &options)
+	if err != nil {
+		return nil, handleGitError(err, "unable to diff trees")
+	}
+	defer diff.Free()
+
+	findOptions, err := git.DefaultDiffFindOptions()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get diff find options")
+	}
+
+	findOptions.Flags |= git.DiffFindRenames
+	err = diff.FindSimilar(&findOptions)
+	if err != nil {
+		return nil, handleGitError(err, "unable to detect renames")
+	}
+
+	result := &Diff{Base: base, Head: head}
+	err = diff.ForEach(func(delta git.DiffDelta, _ float64) (git.DiffForEachHunkCallback, error) {
+		file := &DiffFile{
+			Status:     delta.Status,
+			OldPath:    delta.OldFile.Path,
+			NewPath:    delta.NewFile.Path,
+			OldOid:     delta.OldFile.Oid,
+			NewOid:     delta.NewFile.Oid,
+			OldMode:    git.Filemode(delta.OldFile.Mode),
+			NewMode:    git.Filemode(delta.NewFile.Mode),
+			Similarity: int(delta.Similarity),
+			IsBinary:   delta.Flags&git.DiffFlagBinary != 0,
+		}
+		result.Files = append(result.Files, file)
+
+		return func(hunk git.DiffHunk) (git.DiffForEachLineCallback, error) {
+			diffHunk := &DiffHunk{
+				Header:   hunk.Header,
+				OldStart: hunk.OldStart,
+				OldLines: hunk.OldLines,
+				NewStart: hunk.NewStart,
+				NewLines: hunk.NewLines,
+			}
+			file.Hunks = append(file.Hunks, diffHunk)
+
+			return func(line git.DiffLine) error {
+				switch line.Origin {
+				case git.DiffLineAddition:
+					file.Additions++
+					result.Additions++
+				case git.DiffLineDeletion:
+					file.Deletions++
+					result.Deletions++
+				}
+
+				diffHunk.Lines = append(diffHunk.Lines, &DiffLine{
+					Origin:    line.Origin,
+					OldLineno: line.OldLineno,
+					NewLineno: line.NewLineno,
+					Content:   line.Content,
+				})
+				return nil
+			}, nil
+		}, nil
+	}, git.DiffDetailLines)
+	if err != nil {
+		return nil, handleGitError(err, "unable to iterate over diff")
+	}
+
+	if diffOptions.Patch {
+		result.Patch, err = diff.ToBuf(git.DiffFormatPatch)
+		if err != nil {
+			return nil, handleGitError(err, "unable to format patch")
+		}
+	}
+
+	return result, nil
+}
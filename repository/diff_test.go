@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestDiffCommit(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	root := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a\nb\n", true), writeFile("moved", "1\n2\n3\n4\n", true))
+	second := testCommit(t, "repo", "main", time.Time{},
+		writeFile("README", "a\nc\n", false),
+		FileChange{Action: FileMove, PreviousPath: "moved", Path: "renamed"},
+	)
+
+	diff, err := DiffCommit("repo", root.Commit.String(), 1, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Base != nil || len(diff.Files) != 2 || diff.Additions != 6 || diff.Deletions != 0 {
+		t.Errorf("root commit: expected 2 added files of 6 lines, got %d files, +%d -%d", len(diff.Files), diff.Additions, diff.Deletions)
+	}
+	if diff.Patch != nil {
+		t.Error("expected no patch unless asked for")
+	}
+
+	diff, err = DiffCommit("repo", second.Commit.String(), 1, DiffOptions{Patch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Base.Equal(root.Commit) || diff.Additions != 1 || diff.Deletions != 1 {
+		t.Errorf("expected +1 -1 against the root commit, got +%d -%d from %s", diff.Additions, diff.Deletions, diff.Base)
+	}
+
+	statuses := map[string]git.Delta{}
+	for _, file := range diff.Files {
+		statuses[file.NewPath] = file.Status
+	}
+	if statuses["README"] != git.DeltaModified || statuses["renamed"] != git.DeltaRenamed {
+		t.Errorf("expected README modified and renamed detected, got %v", statuses)
+	}
+	if !bytes.Contains(diff.Patch, []byte("-b\n+c\n")) {
+		t.Errorf("expected the patch to hold the change, got %s", diff.Patch)
+	}
+
+	for _, file := range diff.Files {
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				if line.Origin == git.DiffLineAddition && line.OldLineno != -1 {
+					t.Errorf("expected added lines to have no old line number, got %d", line.OldLineno)
+				}
+			}
+		}
+	}
+
+	if _, err = DiffCommit("repo", second.Commit.String(), 2, DiffOptions{}); err != NotFoundError {
+		t.Errorf("expected NotFoundError for a missing parent, got %v", err)
+	}
+}
+
+func TestCompareRevisions(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	base := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a\n", true))
+	if _, err := CreateBranch("repo", "feature/x", base.Commit.String(), nil); err != nil {
+		t.Fatal(err)
+	}
+	testCommit(t, "repo", "feature/x", time.Time{}, writeFile("feature", "f\n", true))
+	// changes of the base after the branch point are not part of the comparison
+	testCommit(t, "repo", "main", time.Time{}, writeFile("main", "m\n", true))
+
+	diff, err := CompareRevisions("repo", "main", "feature/x", DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Base.Equal(base.Commit) || len(diff.Files) != 1 || diff.Files[0].NewPath != "feature" {
+		t.Errorf("expected only the feature file against the merge base, got %d files from %s", len(diff.Files), diff.Base)
+	}
+}
@@ -8,15 +8,26 @@ import (
 )
 
 var (
-	NotFoundError = errors.New("not found")
+	NotFoundError        = errors.New("not found")
+	AlreadyExistsError   = errors.New("already exists")
+	ConflictError        = errors.New("conflict")
+	InvalidArgumentError = errors.New("invalid argument")
+	ProtectedBranchError = errors.New("protected branch")
 )
 
 func handleGitError(err error, message string) error {
 	var gitError *git.GitError
 	isGitError := errors.As(err, &gitError)
 	if isGitError {
-		if gitError.Code == git.ErrorCodeNotFound {
+		switch gitError.Code {
+		case git.ErrorCodeNotFound, git.ErrorCodeUnbornBranch:
 			return NotFoundError
+		case git.ErrorCodeExists:
+			return fmt.Errorf("%s: %w", message, AlreadyExistsError)
+		case git.ErrorCodeModified, git.ErrorCodeLocked:
+			return fmt.Errorf("%s: %w", message, ConflictError)
+		case git.ErrorCodeInvalidSpec, git.ErrorCodeAmbiguous:
+			return fmt.Errorf("%s: %w: %s", message, InvalidArgumentError, gitError.Message)
 		}
 	}
 	return fmt.Errorf("%s %w", message, err)
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+type EventType string
+
+const (
+	RepositoryCreated EventType = "repository_created"
+	RepositoryDeleted EventType = "repository_deleted"
+	RepositoryMoved   EventType = "repository_moved"
+	ReferencesUpdated EventType = "references_updated"
+	Pushed            EventType = "push"
+)
+
+// IsValid - Report whether the event type is one gituim publishes
+func (t EventType) IsValid() bool {
+	return t == RepositoryCreated || t == RepositoryDeleted || t == RepositoryMoved || t == ReferencesUpdated || t == Pushed
+}
+
+// ReferenceUpdate - Move of a reference, Old is nil when it was created and New when it was deleted
+type ReferenceUpdate struct {
+	Name string
+	Old  *git.Oid
+	New  *git.Oid
+}
+
+// Event - Change of a repository, Previous being its name before it was moved
+type Event struct {
+	Type       EventType
+	Repository string
+	Previous   string
+	Updates    []ReferenceUpdate
+}
+
+// EventListener - Called synchronously after every change, it must not block
+type EventListener func(event Event)
+
+var (
+	listenersMutex sync.RWMutex
+	listeners      []EventListener
+)
+
+// Subscribe - Register a listener for changes made through gituim, including pushes
+func Subscribe(listener EventListener) {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	listeners = append(listeners, listener)
+}
+
+func publish(event Event) {
+	listenersMutex.RLock()
+	defer listenersMutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// publishReferenceUpdate - Publish the move of a single reference
+func publishReferenceUpdate(repositoryName, name string, old, target *git.Oid) {
+	publish(Event{
+		Type:       ReferencesUpdated,
+		Repository: repositoryName,
+		Updates:    []ReferenceUpdate{{Name: name, Old: old, New: target}},
+	})
+}
+
+// publishPush - Publish the reference updates the post-receive hook of a push collected
+func publishPush(repositoryName string, r io.Reader) {
+	updates, err := readHookUpdates(r)
+	if err != nil {
+		log.Printf("unable to read the references pushed to %s: %v", repositoryName, err)
+		return
+	}
+
+	if len(updates) == 0 {
+		return
+	}
+
+	log.Printf("%d references pushed to %s", len(updates), repositoryName)
+	publish(Event{Type: Pushed, Repository: repositoryName, Updates: updates})
+}
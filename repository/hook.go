@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// hookNames - Hooks git runs for the pushes gituim serves
+var hookNames = []string{"pre-receive", "post-receive"}
+
+// RunHook - Run a git hook of a push served by gituim, returning the exit code of the hook.
+// Rejections are written to stderr, which git relays to the client.
+func RunHook(name string, stdin io.Reader, stderr io.Writer) int {
+	var err error
+	switch name {
+	case "pre-receive":
+		err = preReceive(stdin)
+	case "post-receive":
+		err = postReceive(stdin)
+	default:
+		err = fmt.Errorf("unknown hook %s", name)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "gituim: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// preReceive - Check the reference updates of a push before git applies any of them
+func preReceive(stdin io.Reader) error {
+	repositoryName := os.Getenv("GITUIM_REPOSITORY")
+	pusher := &Pusher{User: os.Getenv("GITUIM_PUSHER")}
+	if teams := os.Getenv("GITUIM_PUSHER_TEAMS"); teams != "" {
+		pusher.Teams = strings.Split(teams, ",")
+	}
+
+	// the environment set by git points at the objects of the push, still in quarantine
+	repository, err := git.OpenRepositoryExtended(".", git.RepositoryOpenFromEnv, "")
+	if err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+
+	updates, err := readHookUpdates(stdin)
+	if err != nil {
+		return err
+	}
+
+	return checkReferenceUpdates(repository, repositoryName, pusher, updates...)
+}
+
+// postReceive - Hand the reference updates git applied back to the gituim process serving the push,
+// through the file it named. Only the references the push actually moved are listed.
+func postReceive(stdin io.Reader) error {
+	path := os.Getenv("GITUIM_PUSH_UPDATES")
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open push updates: %w", err)
+	}
+
+	if _, err = io.Copy(file, stdin); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write push updates: %w", err)
+	}
+	return file.Close()
+}
+
+// readHookUpdates - Reference updates given to receive hooks, one "old new name" line each
+func readHookUpdates(r io.Reader) ([]ReferenceUpdate, error) {
+	var updates []ReferenceUpdate
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected hook input %q", scanner.Text())
+		}
+
+		old, err := hookOid(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		target, err := hookOid(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, ReferenceUpdate{Name: fields[2], Old: old, New: target})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read hook input: %w", err)
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+	return updates, nil
+}
+
+// hookOid - Object id given to a hook, nil for the zero id of created and deleted references
+func hookOid(value string) (*git.Oid, error) {
+	oid, err := parseOid(value)
+	if err != nil {
+		return nil, err
+	}
+	if oid.IsZero() {
+		return nil, nil
+	}
+	return oid, nil
+}
+
+// hooksPath - Directory of the hooks run by git for the pushes gituim serves, written on first use
+var hooksPath = sync.OnceValues(func() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate gituim: %w", err)
+	}
+
+	directory := filepath.Join(GDataDirectory, "hooks")
+	if err = os.MkdirAll(directory, 0755); err != nil {
+		return "", fmt.Errorf("unable to create hooks directory: %w", err)
+	}
+
+	quoted := "'" + strings.ReplaceAll(executable, "'", `'\''`) + "'"
+	for _, name := range hookNames {
+		script := fmt.Sprintf("#!/bin/sh\nexec %s hook %s\n", quoted, name)
+		if err = os.WriteFile(filepath.Join(directory, name), []byte(script), 0755); err != nil {
+			return "", fmt.Errorf("unable to write %s hook: %w", name, err)
+		}
+	}
+
+	return directory, nil
+})
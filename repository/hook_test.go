@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostReceivePublishesHookUpdates(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "pushed")
+	first := testCommit(t, "pushed", "main", time.Time{}, writeFile("README", "a", true))
+	second := testCommit(t, "pushed", "main", time.Time{}, writeFile("README", "b", false))
+
+	var events []Event
+	Subscribe(func(event Event) {
+		if event.Repository == "pushed" && event.Type == Pushed {
+			events = append(events, event)
+		}
+	})
+
+	updatesPath := filepath.Join(t.TempDir(), "updates")
+	if err := os.WriteFile(updatesPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITUIM_PUSH_UPDATES", updatesPath)
+
+	zero := strings.Repeat("0", 40)
+	input := first.Commit.String() + " " + second.Commit.String() + " refs/heads/main\n" +
+		zero + " " + first.Commit.String() + " refs/tags/v1\n"
+
+	var stderr bytes.Buffer
+	if code := RunHook("post-receive", strings.NewReader(input), &stderr); code != 0 {
+		t.Fatalf("expected the hook to succeed, got %d: %s", code, stderr.String())
+	}
+
+	// a branch moved through the API while the push runs is not part of it
+	testCommit(t, "pushed", "other", time.Time{}, writeFile("README", "c", true))
+
+	updates, err := os.Open(updatesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updates.Close()
+	publishPush("pushed", updates)
+
+	if len(events) != 1 || len(events[0].Updates) != 2 {
+		t.Fatalf("expected one push of two references, got %+v", events)
+	}
+
+	branch, tag := events[0].Updates[0], events[0].Updates[1]
+	if branch.Name != "refs/heads/main" || !branch.Old.Equal(first.Commit) || !branch.New.Equal(second.Commit) {
+		t.Errorf("unexpected branch update %+v", branch)
+	}
+	if tag.Name != "refs/tags/v1" || tag.Old != nil || !tag.New.Equal(first.Commit) {
+		t.Errorf("unexpected tag update %+v", tag)
+	}
+}
+
+func TestRunHookUnknown(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := RunHook("update", strings.NewReader(""), &stderr); code == 0 {
+		t.Error("expected unknown hooks to fail")
+	}
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// maxLastCommitCaches - Directories whose last commits are cached per repository, the least recently
+// used being pruned past it
+const maxLastCommitCaches = 512
+
+type LastCommit struct {
+	Commit  string         `json:"commit"`
+	Summary string         `json:"summary"`
+	Author  *git.Signature `json:"author"`
+}
+
+// lastCommitCache - Last commits of a directory's entries as of Commit. Stored per commit and path,
+// the history behind a commit never changes so neither do they. Reads refresh the modification time
+// of the files, which pruning goes by.
+type lastCommitCache struct {
+	Path    string                 `json:"path"`
+	Commit  string                 `json:"commit"`
+	Entries map[string]*LastCommit `json:"entries"`
+}
+
+// annotateLastCommits - Attach to the direct entries of tree the last commit that changed them
+func annotateLastCommits(repositoryName string, repository *git.Repository, resolved *resolvedPath, tree *Tree) error {
+	object, err := resolved.revision.Peel(git.ObjectCommit)
+	if err != nil {
+		return fmt.Errorf("last commits need a revision resolving to a commit: %w", InvalidArgumentError)
+	}
+
+	commit, err := object.AsCommit()
+	if err != nil {
+		return handleGitError(err, "unable to get commit")
+	}
+
+	lastCommits, err := getLastCommits(repositoryName, repository, commit, resolved.path)
+	if err != nil {
+		return err
+	}
+
+	prefix := ""
+	if resolved.path != "" {
+		prefix = resolved.path + "/"
+	}
+
+	for _, entry := range tree.Entries {
+		if entry.Path == prefix+entry.Name {
+			entry.LastCommit = lastCommits[entry.Name]
+		}
+	}
+
+	return nil
+}
+
+// getLastCommits - Last commits of the entries of the directory at path, served from the cache when
+// they were already computed for this commit and path
+func getLastCommits(repositoryName string, repository *git.Repository, commit *git.Commit, path string) (map[string]*LastCommit, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	// paths are hashed to make file names of any length and character
+	pathHash := sha256.Sum256([]byte(path))
+	cacheDirectory := name.dataPath("cache", "last-commits")
+	cachePath := filepath.Join(cacheDirectory, commit.Id().String()+"-"+hex.EncodeToString(pathHash[:8])+".json")
+
+	var cache lastCommitCache
+	found, err := readJSONFile(cachePath, &cache)
+	if err != nil {
+		log.Printf("ignoring unreadable last commit cache %s: %v", cachePath, err)
+	} else if found && cache.Path == path && cache.Commit == commit.Id().String() {
+		now := time.Now()
+		_ = os.Chtimes(cachePath, now, now)
+		return cache.Entries, nil
+	}
+
+	entries, err := computeLastCommits(repository, commit, path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeJSONFile(cachePath, &lastCommitCache{Path: path, Commit: commit.Id().String(), Entries: entries})
+	if err != nil {
+		log.Printf("unable to write last commit cache %s: %v", cachePath, err)
+	} else if err = pruneLastCommitCaches(cacheDirectory, maxLastCommitCaches); err != nil {
+		log.Printf("unable to prune last commit caches of %s: %v", repositoryName, err)
+	}
+
+	return entries, nil
+}
+
+// pruneLastCommitCaches - Remove the least recently used cache files of a directory past keep of them
+func pruneLastCommitCaches(directory string, keep int) error {
+	files, err := os.ReadDir(directory)
+	if err != nil || len(files) <= keep {
+		return err
+	}
+
+	type cacheFile struct {
+		name string
+		used time.Time
+	}
+	caches := make([]cacheFile, 0, len(files))
+	for _, file := range files {
+		info, err := file.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// pruned concurrently
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		caches = append(caches, cacheFile{name: file.Name(), used: info.ModTime()})
+	}
+
+	slices.SortFunc(caches, func(a, b cacheFile) int {
+		return b.used.Compare(a.used)
+	})
+	for _, cache := range caches[min(keep, len(caches)):] {
+		if err = os.Remove(filepath.Join(directory, cache.name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// computeLastCommits - Walk history newest first until every entry of the directory at path has
+// been attributed to the first commit whose entry differs from all of its parents
+func computeLastCommits(repository *git.Repository, commit *git.Commit, path string) (map[string]*LastCommit, error) {
+	directories := map[string]map[string]string{}
+
+	// entries - Entry identities (oid and mode) of the directory at path in a commit, nil when missing
+	entries := func(commit *git.Commit) (map[string]string, string, error) {
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, "", handleGitError(err, "unable to get commit tree")
+		}
+
+		if path != "" {
+			entry, err := tree.EntryByPath(path)
+			if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+				return nil, "", nil
+			}
+			if err != nil {
+				return nil, "", handleGitError(err, "unable to lookup tree entry")
+			}
+			if entry.Type != git.ObjectTree {
+				return nil, "", nil
+			}
+
+			tree, err = repository.LookupTree(entry.Id)
+			if err != nil {
+				return nil, "", handleGitError(err, "unable to lookup tree")
+			}
+		}
+
+		treeId := tree.Id().String()
+		if directory, ok := directories[treeId]; ok {
+			return directory, treeId, nil
+		}
+
+		directory := map[string]string{}
+		for i := uint64(0); i < tree.EntryCount(); i++ {
+			entry := tree.EntryByIndex(i)
+			directory[entry.Name] = fmt.Sprintf("%s %o", entry.Id, entry.Filemode)
+		}
+		directories[treeId] = directory
+		return directory, treeId, nil
+	}
+
+	target, _, err := entries(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]*LastCommit{}
+	remaining := map[string]bool{}
+	for name := range target {
+		remaining[name] = true
+	}
+
+	walk, err := repository.Walk()
+	if err != nil {
+		return nil, handleGitError(err, "unable to create revision walker")
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTime)
+	if err = walk.Push(commit.Id()); err != nil {
+		return nil, handleGitError(err, "unable to push revision")
+	}
+
+	var walkErr error
+	err = walk.Iterate(func(current *git.Commit) bool {
+		directory, treeId, err := entries(current)
+		if err != nil {
+			walkErr = err
+			return false
+		}
+		if directory == nil {
+			return true
+		}
+
+		var parents []map[string]string
+		for i := uint(0); i < current.ParentCount(); i++ {
+			parent := current.Parent(i)
+			if parent == nil {
+				walkErr = fmt.Errorf("unable to lookup parent %d of %s", i, current.Id())
+				return false
+			}
+
+			parentDirectory, parentTreeId, err := entries(parent)
+			if err != nil {
+				walkErr = err
+				return false
+			}
+
+			// the directory is unchanged against this parent so nothing here was touched
+			if parentTreeId == treeId {
+				return true
+			}
+			parents = append(parents, parentDirectory)
+		}
+
+		for name := range remaining {
+			identity, ok := directory[name]
+			if !ok || identity != target[name] {
+				continue
+			}
+
+			touched := true
+			for _, parent := range parents {
+				if parent[name] == identity {
+					touched = false
+					break
+				}
+			}
+
+			if touched {
+				result[name] = &LastCommit{
+					Commit:  current.Id().String(),
+					Summary: strings.TrimSpace(current.Summary()),
+					Author:  current.Author(),
+				}
+				delete(remaining, name)
+			}
+		}
+
+		return len(remaining) > 0
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to walk commit history")
+	}
+
+	return result, nil
+}
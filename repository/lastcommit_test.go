@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLastCommitsAfterRevert(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	first := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	second := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "b", false))
+	// back to the tree of the first commit
+	revert := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", false))
+
+	// every lookup runs twice, the second one being served from the cache
+	for _, expected := range []*Commit{first, second, revert, first, revert} {
+		for i := 0; i < 2; i++ {
+			tree, err := LookupTreeByPath("repo", expected.Commit.String(), "", TreeOptions{LastCommits: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lastCommit := tree.Entries[0].LastCommit
+			if lastCommit == nil || lastCommit.Commit != expected.Commit.String() {
+				t.Fatalf("at %s: expected README last changed by it, got %+v", expected.Commit, lastCommit)
+			}
+		}
+	}
+}
+
+func TestPruneLastCommitCaches(t *testing.T) {
+	directory := t.TempDir()
+
+	used := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		file := filepath.Join(directory, fmt.Sprintf("%d.json", i))
+		if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// the oldest files are used last, only use times matter
+		when := used.Add(time.Duration(5-i) * time.Minute)
+		if err := os.Chtimes(file, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pruneLastCommitCaches(directory, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if !slices.Equal(names, []string{"0.json", "1.json"}) {
+		t.Errorf("expected the two most recently used caches to be kept, got %v", names)
+	}
+
+	if err = pruneLastCommitCaches(filepath.Join(directory, "missing"), 2); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+const (
+	DefaultLogLimit = 30
+	MaxLogLimit     = 100
+)
+
+type LogOptions struct {
+	Revision  string
+	Path      string
+	Since     time.Time
+	Until     time.Time
+	Author    string
+	Committer string
+	Message   string
+	Limit     int
+	Cursor    string
+	Follow    bool
+}
+
+type LogEntry struct {
+	Commit *Commit
+	Path   string
+}
+
+type CommitLog struct {
+	Commits []*LogEntry
+	Next    string
+}
+
+// ListCommits - Walk the history reachable from a revision, newest first.
+// With a path only commits changing it are listed, following renames when options.Follow is set,
+// and Message is a regular expression matched against the whole commit message.
+func ListCommits(repositoryName string, options LogOptions) (*CommitLog, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	revision := options.Revision
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	start, err := revparseCommit(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	walk, err := repository.Walk()
+	if err != nil {
+		return nil, handleGitError(err, "unable to create revision walker")
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTime)
+	err = walk.Push(start.Id())
+	if err != nil {
+		return nil, handleGitError(err, "unable to push revision")
+	}
+
+	return walkLog(repository, walk, options)
+}
+
+// SearchCommits - Walk the history reachable from every reference, newest first.
+// Options work as for ListCommits, the revision aside.
+func SearchCommits(repositoryName string, options LogOptions) (*CommitLog, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	walk, err := repository.Walk()
+	if err != nil {
+		return nil, handleGitError(err, "unable to create revision walker")
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTime)
+
+	// references to anything but commits, like tags of trees, are skipped by libgit2
+	err = walk.PushGlob("refs/*")
+	if err != nil {
+		return nil, handleGitError(err, "unable to push references")
+	}
+
+	return walkLog(repository, walk, options)
+}
+
+// walkLog - Collect the commits of a time sorted walk matching the log options, one page at a time
+func walkLog(repository *git.Repository, walk *git.RevWalk, options LogOptions) (*CommitLog, error) {
+	var message *regexp.Regexp
+	if options.Message != "" {
+		var err error
+		message, err = regexp.Compile(options.Message)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message pattern: %v: %w", err, InvalidArgumentError)
+		}
+	}
+
+	limit := min(options.Limit, MaxLogLimit)
+	if limit <= 0 {
+		limit = DefaultLogLimit
+	}
+	path := strings.Trim(options.Path, "/")
+	author := strings.ToLower(options.Author)
+	committer := strings.ToLower(options.Committer)
+	skipping := options.Cursor != ""
+
+	history := &CommitLog{}
+	var walkErr error
+	err := walk.Iterate(func(commit *git.Commit) bool {
+		// path filtering runs first, even on skipped commits, to keep track of renames
+		commitPath := path
+		if path != "" {
+			touched, err := touchesPath(commit, path)
+			if err != nil {
+				walkErr = err
+				return false
+			}
+			if !touched {
+				return true
+			}
+
+			if options.Follow {
+				previous, err := renamedFrom(repository, commit, path)
+				if err != nil {
+					walkErr = err
+					return false
+				}
+				if previous != "" {
+					path = previous
+				}
+			}
+		}
+
+		if skipping {
+			skipping = commit.Id().String() != options.Cursor
+			return true
+		}
+
+		// commit dates are not monotonic (clock skew, rebases) so older commits can hide newer ones
+		when := commit.Committer().When
+		if !options.Since.IsZero() && when.Before(options.Since) {
+			return true
+		}
+		if !options.Until.IsZero() && when.After(options.Until) {
+			return true
+		}
+
+		if author != "" && !matchesSignature(commit.Author(), author) {
+			return true
+		}
+		if committer != "" && !matchesSignature(commit.Committer(), committer) {
+			return true
+		}
+		if message != nil && !message.MatchString(commit.Message()) {
+			return true
+		}
+
+		if len(history.Commits) == limit {
+			history.Next = history.Commits[limit-1].Commit.Commit.String()
+			return false
+		}
+
+		entry, err := GetCommit(commit)
+		if err != nil {
+			walkErr = err
+			return false
+		}
+
+		history.Commits = append(history.Commits, &LogEntry{Commit: entry, Path: commitPath})
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to walk commit history")
+	}
+
+	return history, nil
+}
+
+// matchesSignature - Case insensitive match on the signature name or email
+func matchesSignature(signature *git.Signature, query string) bool {
+	return strings.Contains(strings.ToLower(signature.Name), query) ||
+		strings.Contains(strings.ToLower(signature.Email), query)
+}
+
+// touchesPath - Report whether a commit changed path compared to its parents.
+// Like git log, a merge only counts when it differs from every parent.
+func touchesPath(commit *git.Commit, path string) (bool, error) {
+	entry, err := entryAtPath(commit, path)
+	if err != nil {
+		return false, err
+	}
+
+	if commit.ParentCount() == 0 {
+		return entry != nil, nil
+	}
+
+	for i := uint(0); i < commit.ParentCount(); i++ {
+		parent := commit.Parent(i)
+		if parent == nil {
+			return false, fmt.Errorf("unable to lookup parent %d of %s", i, commit.Id())
+		}
+
+		parentEntry, err := entryAtPath(parent, path)
+		if err != nil {
+			return false, err
+		}
+
+		if sameEntry(entry, parentEntry) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// renamedFrom - Previous path of a file the commit renamed to path, empty when it was not renamed
+func renamedFrom(repository *git.Repository, commit *git.Commit, path string) (string, error) {
+	if commit.ParentCount() == 0 {
+		return "", nil
+	}
+
+	parent := commit.Parent(0)
+	if parent == nil {
+		return "", fmt.Errorf("unable to lookup parent of %s", commit.Id())
+	}
+
+	parentEntry, err := entryAtPath(parent, path)
+	if err != nil || parentEntry != nil {
+		return "", err
+	}
+
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return "", handleGitError(err, "unable to get parent tree")
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", handleGitError(err, "unable to get commit tree")
+	}
+
+	diff, err := repository.DiffTreeToTree(parentTree, tree, nil)
+	if err != nil {
+		return "", handleGitError(err, "unable to diff trees")
+	}
+	defer diff.Free()
+
+	findOptions, err := git.DefaultDiffFindOptions()
+	if err != nil {
+		return "", handleGitError(err, "unable to get diff find options")
+	}
+
+	findOptions.Flags |= git.DiffFindRenames
+	err = diff.FindSimilar(&findOptions)
+	if err != nil {
+		return "", handleGitError(err, "unable to detect renames")
+	}
+
+	deltas, err := diff.NumDeltas()
+	if err != nil {
+		return "", handleGitError(err, "unable to count deltas")
+	}
+
+	for i := 0; i < deltas; i++ {
+		delta, err := diff.Delta(i)
+		if err != nil {
+			return "", handleGitError(err, "unable to get delta")
+		}
+
+		if delta.Status == git.DeltaRenamed && delta.NewFile.Path == path {
+			return delta.OldFile.Path, nil
+		}
+	}
+
+	return "", nil
+}
+
+// entryAtPath - Tree entry at path in the commit tree, nil when it does not exist
+func entryAtPath(commit *git.Commit, path string) (*git.TreeEntry, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get commit tree")
+	}
+
+	entry, err := tree.EntryByPath(path)
+	if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup tree entry")
+	}
+
+	return entry, nil
+}
+
+func sameEntry(a, b *git.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Id.Equal(b.Id) && a.Filemode == b.Filemode
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListCommitsRootCommit(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	root := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "hello", true))
+
+	history, err := ListCommits("repo", LogOptions{Revision: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Commits) != 1 || !history.Commits[0].Commit.Commit.Equal(root.Commit) {
+		t.Fatalf("expected the root commit alone, got %d commits", len(history.Commits))
+	}
+	if parents := history.Commits[0].Commit.Parents; len(parents) != 0 {
+		t.Fatalf("expected no parents, got %v", parents)
+	}
+}
+
+func TestListCommitsLimit(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	for i := 0; i <= MaxLogLimit; i++ {
+		testCommit(t, "repo", "main", time.Time{}, writeFile("file", fmt.Sprint(i), i == 0))
+	}
+
+	tests := []struct {
+		limit    int
+		expected int
+	}{
+		{0, DefaultLogLimit},
+		{-1, DefaultLogLimit},
+		{10, 10},
+		{MaxLogLimit, MaxLogLimit},
+		{MaxLogLimit + 1, MaxLogLimit},
+		{10 * MaxLogLimit, MaxLogLimit},
+	}
+
+	for _, test := range tests {
+		history, err := ListCommits("repo", LogOptions{Revision: "main", Limit: test.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Commits) != test.expected || history.Next == "" {
+			t.Errorf("limit %d: expected %d commits and a cursor, got %d commits and cursor %q",
+				test.limit, test.expected, len(history.Commits), history.Next)
+		}
+	}
+}
+
+func TestListCommitsSinceSkewedDates(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := testCommit(t, "repo", "main", base.Add(10*time.Hour), writeFile("file", "1", true))
+	// committed with a clock running late, older than its own parent
+	testCommit(t, "repo", "main", base.Add(5*time.Hour), writeFile("file", "2", false))
+	last := testCommit(t, "repo", "main", base.Add(20*time.Hour), writeFile("file", "3", false))
+
+	history, err := ListCommits("repo", LogOptions{Revision: "main", Since: base.Add(8 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history.Commits) != 2 ||
+		!history.Commits[0].Commit.Commit.Equal(last.Commit) ||
+		!history.Commits[1].Commit.Commit.Equal(first.Commit) {
+		t.Fatalf("expected the last and first commits, got %d commits", len(history.Commits))
+	}
+}
+
+func TestListCommitsFollowRenames(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	created := testCommit(t, "repo", "main", when, writeFile("config.yml", "a: 1\nb: 2\nc: 3\n", true), writeFile("README", "a", true))
+	testCommit(t, "repo", "main", when.Add(time.Hour), writeFile("README", "b", false))
+	moved := testCommit(t, "repo", "main", when.Add(2*time.Hour), FileChange{Action: FileMove, PreviousPath: "config.yml", Path: "settings/config.yml"})
+	updated := testCommit(t, "repo", "main", when.Add(3*time.Hour), writeFile("settings/config.yml", "a: 1\nb: 2\nc: 4\n", false))
+
+	type step struct {
+		commit *Commit
+		path   string
+	}
+	tests := []struct {
+		follow   bool
+		expected []step
+	}{
+		{false, []step{{updated, "settings/config.yml"}, {moved, "settings/config.yml"}}},
+		{true, []step{{updated, "settings/config.yml"}, {moved, "settings/config.yml"}, {created, "config.yml"}}},
+	}
+
+	for _, test := range tests {
+		history, err := ListCommits("repo", LogOptions{Revision: "main", Path: "settings/config.yml", Follow: test.follow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Commits) != len(test.expected) {
+			t.Errorf("follow %v: expected %d commits, got %d", test.follow, len(test.expected), len(history.Commits))
+			continue
+		}
+		for i, entry := range history.Commits {
+			if !entry.Commit.Commit.Equal(test.expected[i].commit.Commit) || entry.Path != test.expected[i].path {
+				t.Errorf("follow %v: expected %s at %s, got %s at %s", test.follow,
+					test.expected[i].commit.Commit, test.expected[i].path, entry.Commit.Commit, entry.Path)
+			}
+		}
+	}
+
+	// renames seen on the pages skipped by a cursor still apply to the next ones
+	var paths []string
+	options := LogOptions{Revision: "main", Path: "settings/config.yml", Follow: true, Limit: 1}
+	for {
+		history, err := ListCommits("repo", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range history.Commits {
+			paths = append(paths, entry.Path)
+		}
+		if history.Next == "" {
+			break
+		}
+		options.Cursor = history.Next
+	}
+	if strings.Join(paths, ",") != "settings/config.yml,settings/config.yml,config.yml" {
+		t.Errorf("unexpected paths across pages %v", paths)
+	}
+}
+
+func TestSearchCommits(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	base := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	commit := func(branch, author, committer, message string, when time.Time, content string) *Commit {
+		t.Helper()
+		signature := NewSignature(author, strings.ToLower(author)+"@example.com", when)
+		commit, err := CreateCommit("repo", CommitOptions{
+			Branch:    branch,
+			Author:    signature,
+			Committer: NewSignature(committer, strings.ToLower(committer)+"@example.com", when),
+			Message:   message,
+			Changes:   []FileChange{writeFile(branch, content, content == "1")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return commit
+	}
+
+	started := commit("main", "Alice", "Alice", "JIRA-123 start\n", base, "1")
+	if _, err := CreateBranch("repo", "feature", "main", nil); err != nil {
+		t.Fatal(err)
+	}
+	fixed := commit("feature", "Bob", "Bob", "fix JIRA-123\n", base.Add(time.Hour), "1")
+	unrelated := commit("main", "Alice", "Alice", "unrelated\n", base.Add(2*time.Hour), "2")
+	done := commit("main", "Alice", "Carol", "JIRA-123 done\n", base.Add(3*time.Hour), "3")
+
+	// tags of anything but commits are not walked
+	if _, err := CreateTag("repo", "tree", "main^{tree}", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		options  LogOptions
+		expected []*Commit
+	}{
+		{"message across branches", LogOptions{Message: "JIRA-123"}, []*Commit{done, fixed, started}},
+		{"message and author", LogOptions{Message: "JIRA-123", Author: "alice"}, []*Commit{done, started}},
+		{"author email ignoring case", LogOptions{Author: "ALICE@example"}, []*Commit{done, unrelated, started}},
+		{"committer", LogOptions{Committer: "carol"}, []*Commit{done}},
+		{"since", LogOptions{Message: "JIRA-123", Since: base.Add(30 * time.Minute)}, []*Commit{done, fixed}},
+		{"until", LogOptions{Until: base.Add(90 * time.Minute)}, []*Commit{fixed, started}},
+		{"message anchored", LogOptions{Message: "^JIRA-123"}, []*Commit{done, started}},
+	}
+
+	for _, test := range tests {
+		history, err := SearchCommits("repo", test.options)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(history.Commits) != len(test.expected) {
+			t.Errorf("%s: expected %d commits, got %d", test.name, len(test.expected), len(history.Commits))
+			continue
+		}
+		for i, entry := range history.Commits {
+			if !entry.Commit.Commit.Equal(test.expected[i].Commit) {
+				t.Errorf("%s: expected %s at %d, got %s", test.name, test.expected[i].Commit, i, entry.Commit.Commit)
+			}
+		}
+	}
+
+	if _, err := SearchCommits("repo", LogOptions{Message: "("}); !errors.Is(err, InvalidArgumentError) {
+		t.Errorf("expected InvalidArgumentError for an invalid pattern, got %v", err)
+	}
+}
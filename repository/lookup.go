@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	git "github.com/libgit2/git2go/v34"
 )
 
@@ -11,12 +15,31 @@ type Commit struct {
 	Message   string
 	Author    *git.Signature
 	Committer *git.Signature
-	Parent    *git.Commit
+	Parents   []*git.Oid
 }
 
 type Tree struct {
 	Tree    *git.Oid
-	Entries []*git.TreeEntry
+	Path    string
+	Entries []*TreeEntry
+	Next    string
+}
+
+type TreeEntry struct {
+	Oid        *git.Oid
+	Name       string
+	Path       string
+	Mode       git.Filemode
+	Type       git.ObjectType
+	Size       int64
+	LastCommit *LastCommit
+}
+
+type TreeOptions struct {
+	Recursive   bool
+	Limit       int
+	Cursor      string
+	LastCommits bool
 }
 
 type Blob struct {
@@ -27,10 +50,27 @@ type Blob struct {
 }
 
 type Tag struct {
-	Tag    string
-	Commit *Commit
+	Tag        string
+	Annotated  bool
+	Oid        *git.Oid
+	Tagger     *git.Signature
+	Message    string
+	Target     *git.Oid
+	TargetType git.ObjectType
+	Commit     *Commit
 }
 
+const (
+	DefaultTreeLimit = 1000
+	MaxTreeLimit     = 5000
+)
+
+// errStopWalk - Returned from tree walk callbacks to stop walking early
+var errStopWalk = errors.New("stop walk")
+
+// maxTagDepth - Bound on tag of tag chains, mirroring the peel limit of git
+const maxTagDepth = 16
+
 // LookupCommit - Lookup for commit oid
 func LookupCommit(repositoryName, commitId string) (*Commit, error) {
 	repository, err := openRepositoryNoSearch(repositoryName)
@@ -38,9 +78,9 @@ func LookupCommit(repositoryName, commitId string) (*Commit, error) {
 		return nil, handleGitError(err, "unable to open repository")
 	}
 
-	oid, err := git.NewOid(commitId)
+	oid, err := parseOid(commitId)
 	if err != nil {
-		return nil, handleGitError(err, "unable to parse oid")
+		return nil, err
 	}
 
 	commit, err := repository.LookupCommit(oid)
@@ -51,16 +91,17 @@ func LookupCommit(repositoryName, commitId string) (*Commit, error) {
 	return GetCommit(commit)
 }
 
-// LookupTree - Lookup for tree oid
-func LookupTree(repositoryName, treeId string) (*Tree, error) {
+// LookupTree - Lookup for tree oid, anything else being resolved as a revision and its root tree
+func LookupTree(repositoryName, treeId string, options TreeOptions) (*Tree, error) {
 	repository, err := openRepositoryNoSearch(repositoryName)
 	if err != nil {
 		return nil, handleGitError(err, "unable to open repository")
 	}
 
+	// the route of tree oids also matches revisions given without a path, like /tree/main
 	oid, err := git.NewOid(treeId)
 	if err != nil {
-		return nil, handleGitError(err, "unable to parse oid")
+		return LookupTreeByPath(repositoryName, treeId, "", options)
 	}
 
 	tree, err := repository.LookupTree(oid)
@@ -68,7 +109,7 @@ func LookupTree(repositoryName, treeId string) (*Tree, error) {
 		return nil, handleGitError(err, "unable to lookup commit")
 	}
 
-	return GetTree(tree)
+	return GetTree(tree, "", options)
 }
 
 // LookupBlob - Lookup for blob oid
@@ -78,9 +119,9 @@ func LookupBlob(repositoryName, blobId string) (*Blob, error) {
 		return nil, handleGitError(err, "unable to open repository")
 	}
 
-	oid, err := git.NewOid(blobId)
+	oid, err := parseOid(blobId)
 	if err != nil {
-		return nil, handleGitError(err, "unable to parse oid")
+		return nil, err
 	}
 
 	blob, err := repository.LookupBlob(oid)
@@ -91,37 +132,132 @@ func LookupBlob(repositoryName, blobId string) (*Blob, error) {
 	return GetBlob(blob)
 }
 
-// LookupTag - Lookup for tag name
-func LookupTag(repositoryName, tagName string) (*Tag, error) {
+// LookupTreeByPath - Lookup for the tree at path in a revision, the root tree when path is empty
+func LookupTreeByPath(repositoryName, revision, path string, options TreeOptions) (*Tree, error) {
 	repository, err := openRepositoryNoSearch(repositoryName)
 	if err != nil {
 		return nil, handleGitError(err, "unable to open repository")
 	}
 
-	object, err := repository.RevparseSingle(tagName)
+	resolved, err := lookupPath(repository, revision, path)
 	if err != nil {
-		return nil, handleGitError(err, "unable to rev parse tag")
+		return nil, err
 	}
 
-	tag, err := repository.LookupTag(object.Id())
+	if resolved.object.Type() != git.ObjectTree {
+		return nil, NotFoundError
+	}
+
+	tree, err := resolved.object.AsTree()
 	if err != nil {
-		return nil, handleGitError(err, "unable to lookup tag")
+		return nil, handleGitError(err, "unable to get tree")
 	}
 
-	commit, err := repository.LookupCommit(tag.TargetId())
+	result, err := GetTree(tree, resolved.path, options)
 	if err != nil {
-		return nil, handleGitError(err, "unable to lookup commit")
+		return nil, err
 	}
 
-	tagCommit, err := GetCommit(commit)
+	if options.LastCommits {
+		err = annotateLastCommits(repositoryName, repository, resolved, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// LookupBlobByPath - Lookup for the blob at path in a revision
+func LookupBlobByPath(repositoryName, revision, path string) (*Blob, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
 	if err != nil {
-		return nil, handleGitError(err, "unable to get tag commit")
+		return nil, handleGitError(err, "unable to open repository")
 	}
 
-	return &Tag{
-		Tag:    tagName,
-		Commit: tagCommit,
-	}, nil
+	resolved, err := lookupPath(repository, revision, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolved.object.Type() != git.ObjectBlob {
+		return nil, NotFoundError
+	}
+
+	blob, err := resolved.object.AsBlob()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get blob")
+	}
+
+	return GetBlob(blob)
+}
+
+// LookupTag - Lookup for tag name, peeling annotated tags (including tags of tags) down to their target
+func LookupTag(repositoryName, tagName string) (*Tag, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	reference, err := repository.References.Lookup("refs/tags/" + tagName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup tag reference")
+	}
+
+	reference, err = reference.Resolve()
+	if err != nil {
+		return nil, handleGitError(err, "unable to resolve tag reference")
+	}
+
+	object, err := repository.Lookup(reference.Target())
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup tag target")
+	}
+
+	result := &Tag{
+		Tag: tagName,
+		Oid: object.Id(),
+	}
+
+	for depth := 0; object.Type() == git.ObjectTag; depth++ {
+		if depth == maxTagDepth {
+			return nil, fmt.Errorf("tag %s is nested more than %d levels deep", tagName, maxTagDepth)
+		}
+
+		tag, err := object.AsTag()
+		if err != nil {
+			return nil, handleGitError(err, "unable to get tag")
+		}
+
+		// the outermost tag is the one the reference names
+		if !result.Annotated {
+			result.Annotated = true
+			result.Tagger = tag.Tagger()
+			result.Message = tag.Message()
+		}
+
+		object, err = repository.Lookup(tag.TargetId())
+		if err != nil {
+			return nil, handleGitError(err, "unable to lookup tag target")
+		}
+	}
+
+	result.Target = object.Id()
+	result.TargetType = object.Type()
+
+	if object.Type() == git.ObjectCommit {
+		commit, err := object.AsCommit()
+		if err != nil {
+			return nil, handleGitError(err, "unable to get commit")
+		}
+
+		result.Commit, err = GetCommit(commit)
+		if err != nil {
+			return nil, handleGitError(err, "unable to get tag commit")
+		}
+	}
+
+	return result, nil
 }
 
 // GetCommit = Get commit information
@@ -143,26 +279,108 @@ func GetCommit(commit *git.Commit) (*Commit, error) {
 		Message:   commit.Message(),
 		Author:    commit.Author(),
 		Committer: commit.Committer(),
-		Parent:    commit.Parent(0),
+		Parents:   getParentIds(commit),
 	}, nil
 }
 
-// GetTree - Get tree entries
-func GetTree(tree *git.Tree) (*Tree, error) {
-	var entries []*git.TreeEntry
-	err := tree.Walk(func(s string, entry *git.TreeEntry) error {
-		entries = append(entries, entry)
-		return nil
-	})
+// parseOid - Object id given by a client, InvalidArgumentError when it is not one
+func parseOid(value string) (*git.Oid, error) {
+	oid, err := git.NewOid(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object id %q: %w", value, InvalidArgumentError)
+	}
+	return oid, nil
+}
+
+// getParentIds - All parent ids of a commit, empty for root commits
+func getParentIds(commit *git.Commit) []*git.Oid {
+	parents := make([]*git.Oid, 0, commit.ParentCount())
+	for i := uint(0); i < commit.ParentCount(); i++ {
+		parents = append(parents, commit.ParentId(i))
+	}
+	return parents
+}
+
+// GetTree - Get a page of tree entries, only the direct children unless options.Recursive is set.
+// path is the location of the tree in its commit and prefixes the entry paths.
+func GetTree(tree *git.Tree, path string, options TreeOptions) (*Tree, error) {
+	limit := min(options.Limit, MaxTreeLimit)
+	if limit <= 0 {
+		limit = DefaultTreeLimit
+	}
+
+	prefix := strings.Trim(path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	result := &Tree{
+		Tree: tree.Id(),
+		Path: strings.Trim(path, "/"),
+	}
+	skipping := options.Cursor != ""
+
+	// add - Collect an entry, returning false once the page is full
+	add := func(root string, entry *git.TreeEntry) bool {
+		entryPath := prefix + root + entry.Name
+		if skipping {
+			skipping = entryPath != options.Cursor
+			return true
+		}
+
+		if len(result.Entries) == limit {
+			result.Next = result.Entries[limit-1].Path
+			return false
+		}
+
+		result.Entries = append(result.Entries, &TreeEntry{
+			Oid:  entry.Id,
+			Name: entry.Name,
+			Path: entryPath,
+			Mode: entry.Filemode,
+			Type: entry.Type,
+			Size: -1,
+		})
+		return true
+	}
 
+	if options.Recursive {
+		err := tree.Walk(func(root string, entry *git.TreeEntry) error {
+			if !add(root, entry) {
+				return errStopWalk
+			}
+			return nil
+		})
+		if err != nil && err != errStopWalk {
+			return nil, handleGitError(err, "unable to walk throught the tree")
+		}
+	} else {
+		for i := uint64(0); i < tree.EntryCount(); i++ {
+			if !add("", tree.EntryByIndex(i)) {
+				break
+			}
+		}
+	}
+
+	odb, err := tree.Owner().Odb()
 	if err != nil {
-		return nil, handleGitError(err, "unable to walk throught the tree")
+		return nil, handleGitError(err, "unable to open object database")
 	}
 
-	return &Tree{
-		Tree:    tree.Id(),
-		Entries: entries,
-	}, nil
+	// only the headers are read, blob contents stay on disk
+	for _, entry := range result.Entries {
+		if entry.Type != git.ObjectBlob {
+			continue
+		}
+
+		size, _, err := odb.ReadHeader(entry.Oid)
+		if err != nil {
+			return nil, handleGitError(err, "unable to read blob header")
+		}
+		entry.Size = int64(size)
+	}
+
+	return result, nil
 }
 
 // GetBlob - Get blob contents
@@ -178,3 +396,64 @@ func GetBlob(blob *git.Blob) (*Blob, error) {
 		Contents: blob.Contents(),
 	}, nil
 }
+
+// resolvedPath - Object found at a path of a revision, with the tree entry leading to it
+// unless path is the root tree of the revision
+type resolvedPath struct {
+	revision *git.Object
+	object   *git.Object
+	entry    *git.TreeEntry
+	path     string
+}
+
+// lookupPath - Resolve a revision (branch, tag, short oid or revspec) and navigate its tree to path.
+// Branch names containing slashes are split from the path by trying the shortest revision first,
+// so the returned path is what is left once the revision has been split off.
+func lookupPath(repository *git.Repository, revision, path string) (*resolvedPath, error) {
+	resolved, err := lookupEntry(repository, revision, path)
+	if err != nil || resolved.entry == nil {
+		return resolved, err
+	}
+
+	resolved.object, err = repository.Lookup(resolved.entry.Id)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup object")
+	}
+	return resolved, nil
+}
+
+// lookupEntry - Resolve a revision and path like lookupPath, without loading the object at path
+func lookupEntry(repository *git.Repository, revision, path string) (*resolvedPath, error) {
+	path = strings.Trim(path, "/")
+
+	object, err := repository.RevparseSingle(revision)
+	for git.IsErrorCode(err, git.ErrorCodeNotFound) && path != "" {
+		segment, rest, _ := strings.Cut(path, "/")
+		revision, path = revision+"/"+segment, rest
+		object, err = repository.RevparseSingle(revision)
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to rev parse revision")
+	}
+
+	root, err := object.Peel(git.ObjectTree)
+	if err != nil {
+		return nil, handleGitError(err, "unable to peel revision to a tree")
+	}
+
+	if path == "" {
+		return &resolvedPath{revision: object, object: root}, nil
+	}
+
+	tree, err := root.AsTree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get tree")
+	}
+
+	entry, err := tree.EntryByPath(path)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup path")
+	}
+
+	return &resolvedPath{revision: object, entry: entry, path: path}, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLookupTreeRevision(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	commit := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "hello", true))
+
+	for _, value := range []string{"main", "refs/heads/main", commit.Tree.Id().String()} {
+		tree, err := LookupTree("repo", value, TreeOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", value, err)
+		}
+		if !tree.Tree.Equal(commit.Tree.Id()) || len(tree.Entries) != 1 {
+			t.Errorf("%s: expected the root tree of main, got %s", value, tree.Tree)
+		}
+	}
+
+	if _, err := LookupTree("repo", "unknown", TreeOptions{}); !errors.Is(err, NotFoundError) {
+		t.Errorf("unknown revision: expected NotFoundError, got %v", err)
+	}
+}
+
+func TestLookupInvalidOid(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("README", "hello", true))
+
+	if _, err := LookupCommit("repo", "main"); !errors.Is(err, InvalidArgumentError) {
+		t.Errorf("commit: expected InvalidArgumentError, got %v", err)
+	}
+	if _, err := LookupBlob("repo", "main"); !errors.Is(err, InvalidArgumentError) {
+		t.Errorf("blob: expected InvalidArgumentError, got %v", err)
+	}
+	if _, err := DiffCommit("repo", "main", 0, DiffOptions{}); !errors.Is(err, InvalidArgumentError) {
+		t.Errorf("diff: expected InvalidArgumentError, got %v", err)
+	}
+}
+
+func TestMergeCommitParents(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	base := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	other := testCommit(t, "repo", "other", time.Time{}, writeFile("OTHER", "b", true))
+
+	repository, err := openRepositoryNoSearch("repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseCommit, err := repository.LookupCommit(base.Commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCommit, err := repository.LookupCommit(other.Commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := NewSignature("Test", "test@example.com", time.Time{})
+	merge, err := repository.CreateCommit("refs/heads/merged", signature, signature, "merge\n", base.Tree, baseCommit, otherCommit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := LookupCommit("repo", merge.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit.Parents) != 2 || !commit.Parents[0].Equal(base.Commit) || !commit.Parents[1].Equal(other.Commit) {
+		t.Errorf("expected both parents of the merge, got %v", commit.Parents)
+	}
+
+	branch, err := GetBranch("repo", "merged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branch.Parents) != 2 || !branch.Parents[1].Equal(other.Commit) {
+		t.Errorf("expected both parents on the branch, got %v", branch.Parents)
+	}
+
+	root, err := LookupCommit("repo", base.Commit.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Parents == nil || len(root.Parents) != 0 {
+		t.Errorf("expected no parents for the root commit, got %v", root.Parents)
+	}
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+const (
+	maxDescriptionLength = 1000
+	maxTopics            = 20
+	maxProperties        = 50
+	maxPropertyLength    = 1000
+)
+
+// defaultDescription - Placeholder git init writes, reported as no description
+const defaultDescription = "Unnamed repository; edit this file 'description' to name the repository."
+
+var (
+	topicPattern       = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+	propertyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+	// metadataMutex - Serializes the read-modify-write cycles of metadata files
+	metadataMutex sync.Mutex
+)
+
+// RepositoryUpdate - Changes of the metadata of a repository, nil fields are left untouched.
+// Properties are merged into the existing ones, a nil value removing the property.
+type RepositoryUpdate struct {
+	Description   *string
+	DefaultBranch *string
+	Topics        *[]string
+	Properties    map[string]*string
+}
+
+// repositoryMetadata - Metadata git has no place for, kept in the gituim folder
+type repositoryMetadata struct {
+	Topics     []string          `json:"topics"`
+	Properties map[string]string `json:"properties"`
+}
+
+// UpdateRepository - Edit the description, default branch, topics and properties of a repository.
+// The description is git's own description file and the default branch is where HEAD points.
+func UpdateRepository(repositoryName string, update RepositoryUpdate) (*Repository, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := openRepository(name)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	if err = checkRepositoryUpdate(update); err != nil {
+		return nil, err
+	}
+
+	// everything that can be refused is checked before anything changes
+	if update.DefaultBranch != nil {
+		if err = checkDefaultBranch(repository, *update.DefaultBranch); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = applyRepositoryUpdate(name, repository, update); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Metadata of %s updated", repositoryName)
+	return GetRepositoryInfo(repositoryName)
+}
+
+// applyRepositoryUpdate - Write a checked update. The metadata is merged first, as it can still be
+// refused, but only written once git took the default branch, so a failure leaves it untouched.
+func applyRepositoryUpdate(name RepositoryName, repository *git.Repository, update RepositoryUpdate) error {
+	var metadata *repositoryMetadata
+	if update.Topics != nil || update.Properties != nil {
+		metadataMutex.Lock()
+		defer metadataMutex.Unlock()
+
+		var err error
+		if metadata, err = mergeMetadata(name, update); err != nil {
+			return err
+		}
+	}
+
+	if update.DefaultBranch != nil {
+		if err := setDefaultBranch(name.String(), repository, *update.DefaultBranch); err != nil {
+			return err
+		}
+	}
+
+	if update.Description != nil {
+		description := strings.TrimSpace(*update.Description) + "\n"
+		if err := os.WriteFile(filepath.Join(name.path(), "description"), []byte(description), 0644); err != nil {
+			return fmt.Errorf("unable to save description: %w", err)
+		}
+	}
+
+	if metadata != nil {
+		if err := writeJSONFile(name.dataPath("metadata.json"), metadata); err != nil {
+			return fmt.Errorf("unable to save metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+func checkRepositoryUpdate(update RepositoryUpdate) error {
+	if update.Description != nil && len(*update.Description) > maxDescriptionLength {
+		return fmt.Errorf("description longer than %d bytes: %w", maxDescriptionLength, InvalidArgumentError)
+	}
+
+	if update.Topics != nil {
+		if len(*update.Topics) > maxTopics {
+			return fmt.Errorf("more than %d topics: %w", maxTopics, InvalidArgumentError)
+		}
+		for _, topic := range *update.Topics {
+			if !topicPattern.MatchString(topic) {
+				return fmt.Errorf("invalid topic %q: %w", topic, InvalidArgumentError)
+			}
+		}
+	}
+
+	for key, value := range update.Properties {
+		if !propertyKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid property %q: %w", key, InvalidArgumentError)
+		}
+		if value != nil && len(*value) > maxPropertyLength {
+			return fmt.Errorf("property %s longer than %d bytes: %w", key, maxPropertyLength, InvalidArgumentError)
+		}
+	}
+	return nil
+}
+
+// checkDefaultBranch - Make sure HEAD can point at a branch, which must exist unless the repository has none yet
+func checkDefaultBranch(repository *git.Repository, branchName string) error {
+	refName := "refs/heads/" + branchName
+	if valid, err := git.ReferenceNameIsValid(refName); err != nil || !valid || branchName == "" {
+		return fmt.Errorf("invalid branch name %q: %w", branchName, InvalidArgumentError)
+	}
+
+	empty, err := repository.IsEmpty()
+	if err != nil {
+		return handleGitError(err, "unable to check repository")
+	}
+
+	if !empty {
+		if _, err = repository.LookupBranch(branchName, git.BranchLocal); err != nil {
+			return handleGitError(err, "unable to lookup branch")
+		}
+	}
+	return nil
+}
+
+// setDefaultBranch - Point HEAD at a branch, publishing the move when it changes what HEAD resolves to
+func setDefaultBranch(repositoryName string, repository *git.Repository, branchName string) error {
+	previous, err := getDefaultBranch(repository)
+	if err != nil {
+		return err
+	}
+	if previous == branchName {
+		return nil
+	}
+
+	old := headTarget(repository)
+	if err = repository.SetHead("refs/heads/" + branchName); err != nil {
+		return handleGitError(err, "unable to set default branch")
+	}
+
+	if target := headTarget(repository); old != nil || target != nil {
+		publishReferenceUpdate(repositoryName, "HEAD", old, target)
+	}
+	return nil
+}
+
+// headTarget - Commit HEAD resolves to, nil when its branch is not born yet
+func headTarget(repository *git.Repository) *git.Oid {
+	head, err := repository.Head()
+	if err != nil {
+		return nil
+	}
+	return head.Target()
+}
+
+// getDefaultBranch - Branch HEAD points at, born or not, empty when HEAD is detached
+func getDefaultBranch(repository *git.Repository) (string, error) {
+	head, err := repository.References.Lookup("HEAD")
+	if err != nil {
+		return "", handleGitError(err, "unable to lookup HEAD")
+	}
+
+	if head.Type() != git.ReferenceSymbolic {
+		return "", nil
+	}
+	return strings.TrimPrefix(head.SymbolicTarget(), "refs/heads/"), nil
+}
+
+func getDescription(name RepositoryName) (string, error) {
+	data, err := os.ReadFile(filepath.Join(name.path(), "description"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read description: %w", err)
+	}
+
+	description := strings.TrimSpace(string(data))
+	if description == defaultDescription {
+		return "", nil
+	}
+	return description, nil
+}
+
+// mergeMetadata - Metadata of a repository with the update applied, to be written by the caller
+// holding the metadata mutex
+func mergeMetadata(name RepositoryName, update RepositoryUpdate) (*repositoryMetadata, error) {
+	metadata, err := readMetadata(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Topics != nil {
+		metadata.Topics = uniqueSorted(*update.Topics)
+	}
+
+	for key, value := range update.Properties {
+		if value == nil {
+			delete(metadata.Properties, key)
+		} else {
+			metadata.Properties[key] = *value
+		}
+	}
+	if len(metadata.Properties) > maxProperties {
+		return nil, fmt.Errorf("more than %d properties: %w", maxProperties, InvalidArgumentError)
+	}
+	return metadata, nil
+}
+
+func readMetadata(name RepositoryName) (*repositoryMetadata, error) {
+	metadata := &repositoryMetadata{}
+	if _, err := readJSONFile(name.dataPath("metadata.json"), metadata); err != nil {
+		return nil, fmt.Errorf("unable to read metadata: %w", err)
+	}
+
+	if metadata.Topics == nil {
+		metadata.Topics = []string{}
+	}
+	if metadata.Properties == nil {
+		metadata.Properties = map[string]string{}
+	}
+	return metadata, nil
+}
+
+func uniqueSorted(values []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
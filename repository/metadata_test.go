@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUpdateRepository(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "meta")
+	main := testCommit(t, "meta", "main", time.Time{}, writeFile("README", "a", true))
+	stable := testCommit(t, "meta", "stable", time.Time{}, writeFile("README", "b", true))
+
+	var updates []ReferenceUpdate
+	Subscribe(func(event Event) {
+		if event.Repository == "meta" && event.Type == ReferencesUpdated {
+			updates = append(updates, event.Updates...)
+		}
+	})
+
+	description, mainBranch, stableBranch := "  Service of the team  ", "main", "stable"
+	first, value := "first", "value"
+	topics := []string{"go", "api", "go"}
+	if _, err := UpdateRepository("meta", RepositoryUpdate{DefaultBranch: &mainBranch}); err != nil {
+		t.Fatal(err)
+	}
+	updates = nil
+
+	info, err := UpdateRepository("meta", RepositoryUpdate{
+		Description:   &description,
+		DefaultBranch: &stableBranch,
+		Topics:        &topics,
+		Properties:    map[string]*string{"owner": &first, "tier": &value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Description != "Service of the team" || info.DefaultBranch != "stable" ||
+		!slices.Equal(info.Topics, []string{"api", "go"}) || info.Properties["owner"] != "first" || info.Properties["tier"] != "value" {
+		t.Errorf("unexpected repository %+v", info)
+	}
+
+	if len(updates) != 1 || updates[0].Name != "HEAD" || !updates[0].Old.Equal(main.Commit) || !updates[0].New.Equal(stable.Commit) {
+		t.Errorf("expected the move of HEAD from main to stable, got %+v", updates)
+	}
+
+	// properties merge, a nil value removing one, and an unchanged default branch publishes nothing
+	updates = nil
+	info, err = UpdateRepository("meta", RepositoryUpdate{
+		DefaultBranch: &stableBranch,
+		Properties:    map[string]*string{"tier": nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Properties) != 1 || info.Properties["owner"] != "first" || !slices.Equal(info.Topics, []string{"api", "go"}) {
+		t.Errorf("unexpected metadata %+v", info)
+	}
+	if len(updates) != 0 {
+		t.Errorf("expected no update of HEAD, got %+v", updates)
+	}
+}
+
+func TestUpdateRepositoryRefusedLeavesRepository(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "meta")
+	testCommit(t, "meta", "main", time.Time{}, writeFile("README", "a", true))
+	testCommit(t, "meta", "stable", time.Time{}, writeFile("README", "b", true))
+
+	mainBranch, stableBranch, missing := "main", "stable", "missing"
+	topics := []string{"before"}
+	if _, err := UpdateRepository("meta", RepositoryUpdate{DefaultBranch: &mainBranch, Topics: &topics}); err != nil {
+		t.Fatal(err)
+	}
+
+	// too many properties are only known once merged with the existing ones
+	properties := map[string]*string{}
+	for i := 0; i <= maxProperties; i++ {
+		value := "value"
+		properties[fmt.Sprintf("key%d", i)] = &value
+	}
+	changed := []string{"after"}
+
+	tests := []RepositoryUpdate{
+		{DefaultBranch: &stableBranch, Topics: &changed, Properties: properties},
+		{DefaultBranch: &missing, Topics: &changed},
+		{DefaultBranch: &stableBranch, Topics: &[]string{"Not a topic"}},
+	}
+	for i, update := range tests {
+		if _, err := UpdateRepository("meta", update); err == nil {
+			t.Errorf("%d: expected the update to be refused", i)
+		}
+
+		info, err := GetRepositoryInfo("meta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.DefaultBranch != "main" || !slices.Equal(info.Topics, []string{"before"}) || len(info.Properties) != 0 {
+			t.Errorf("%d: expected the repository untouched, got %+v", i, info)
+		}
+	}
+
+	if _, err := UpdateRepository("meta", RepositoryUpdate{DefaultBranch: &missing}); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError for a missing branch, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxNameLength - Longest repository or namespace name, namespaces included
+	maxNameLength = 255
+	// maxNamespaceDepth - Most namespaces a repository can be nested in
+	maxNamespaceDepth = 8
+)
+
+// segmentPattern - Characters allowed in each part of a name, without leading dots or separators
+var segmentPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// reservedSegments - Words following repository names in routes, a namespace or repository named
+// like one of them would make paths such as /repositories/team/branches ambiguous
+var reservedSegments = map[string]bool{
+	"access": true, "archive": true, "blame": true, "blob": true, "blobs": true, "branches": true,
+	"commits": true, "compare": true, "git-receive-pack": true, "git-upload-pack": true, "hooks": true,
+	"info": true, "move": true, "protections": true, "raw": true, "search": true, "tags": true, "tree": true,
+}
+
+// RepositoryName - Name of a repository known to resolve to a directory under GRepositoryPrefix,
+// its namespaces separated by slashes. ParseRepositoryName is the only way to get one and paths are
+// only ever built from them.
+type RepositoryName struct {
+	name string
+}
+
+// ParseRepositoryName - Check a repository name, InvalidArgumentError when it could resolve
+// anywhere but to its own directory of the storage root
+func ParseRepositoryName(name string) (RepositoryName, error) {
+	if err := checkName(name); err != nil {
+		return RepositoryName{}, err
+	}
+	return RepositoryName{name: name}, nil
+}
+
+// IsReservedName - Report whether a namespace or repository cannot be named segment
+func IsReservedName(segment string) bool {
+	return reservedSegments[segment]
+}
+
+func (n RepositoryName) String() string {
+	return n.name
+}
+
+// Namespace - Namespace the repository is in, empty at the root
+func (n RepositoryName) Namespace() string {
+	if i := strings.LastIndex(n.name, "/"); i >= 0 {
+		return n.name[:i]
+	}
+	return ""
+}
+
+// Base - Name of the repository inside its namespace
+func (n RepositoryName) Base() string {
+	return n.name[strings.LastIndex(n.name, "/")+1:]
+}
+
+// path - Directory of the bare repository
+func (n RepositoryName) path() string {
+	return namePath(n.name)
+}
+
+// dataPath - Path of gituim's own files, kept in a gituim folder inside the bare repository
+func (n RepositoryName) dataPath(elem ...string) string {
+	return filepath.Join(append([]string{n.path(), "gituim"}, elem...)...)
+}
+
+// checkName - Make sure a repository or namespace name stays within the storage root
+func checkName(name string) error {
+	segments := strings.Split(name, "/")
+	if len(name) > maxNameLength || len(segments) > maxNamespaceDepth+1 {
+		return fmt.Errorf("invalid name %q, too long: %w", name, InvalidArgumentError)
+	}
+
+	for _, segment := range segments {
+		if !segmentPattern.MatchString(segment) {
+			return fmt.Errorf("invalid name %q: %w", name, InvalidArgumentError)
+		}
+		if reservedSegments[segment] {
+			return fmt.Errorf("name %q uses the reserved word %s: %w", name, segment, InvalidArgumentError)
+		}
+	}
+
+	relative, err := filepath.Rel(filepath.Clean(GRepositoryPrefix), namePath(name))
+	if err != nil || relative != filepath.FromSlash(name) {
+		return fmt.Errorf("invalid name %q: %w", name, InvalidArgumentError)
+	}
+
+	// the data directory defaults to a folder of the storage root
+	if inData, err := filepath.Rel(filepath.Clean(GDataDirectory), namePath(name)); err == nil && !strings.HasPrefix(inData, "..") {
+		return fmt.Errorf("name %q is reserved: %w", name, InvalidArgumentError)
+	}
+
+	return nil
+}
+
+// namePath - Directory of a repository or namespace, for checked names only
+func namePath(name string) string {
+	return filepath.Join(GRepositoryPrefix, filepath.FromSlash(name))
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestParseRepositoryName(t *testing.T) {
+	useTemporaryRoot(t)
+
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"repo", true},
+		{"team/repo", true},
+		{"a.b-c_d", true},
+		{"Repo2", true},
+		{strings.Repeat("a", 100), true},
+		{strings.Repeat("a/", maxNamespaceDepth) + "a", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/..", false},
+		{"a/../..", false},
+		{"../outside", false},
+		{"a/./b", false},
+		{"a//b", false},
+		{"a/", false},
+		{"/", false},
+		{"/etc", false},
+		{"/tmp/repo", false},
+		{"%2e%2e", false},
+		{"%2e%2e/outside", false},
+		{"a%2fb", false},
+		{"a%2Fb", false},
+		{"..%2foutside", false},
+		{`a\b`, false},
+		{`..\outside`, false},
+		{".hidden", false},
+		{"team/.hidden", false},
+		{"-repo", false},
+		{"_repo", false},
+		{".gituim", false},
+		{".git", false},
+		{"branches", false},
+		{"team/tree", false},
+		{"access/repo", false},
+		{"git-receive-pack", false},
+		{"a b", false},
+		{"a\x00b", false},
+		{strings.Repeat("a", 101), false},
+		{strings.Repeat(strings.Repeat("a", 100)+"/", 2) + strings.Repeat("a", 56), false},
+		{strings.Repeat("a/", maxNamespaceDepth+1) + "a", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseRepositoryName(test.name)
+		if test.valid && err != nil {
+			t.Errorf("%q: expected a valid name, got %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, InvalidArgumentError) {
+			t.Errorf("%q: expected InvalidArgumentError, got %v", test.name, err)
+		}
+	}
+}
+
+func TestParseRepositoryNameDataDirectory(t *testing.T) {
+	root := useTemporaryRoot(t)
+	GDataDirectory = filepath.Join(root, "root", "data")
+
+	for _, name := range []string{"data", "data/repo"} {
+		if _, err := ParseRepositoryName(name); !errors.Is(err, InvalidArgumentError) {
+			t.Errorf("%q: expected InvalidArgumentError, got %v", name, err)
+		}
+	}
+	if _, err := ParseRepositoryName("database"); err != nil {
+		t.Errorf("expected a valid name, got %v", err)
+	}
+}
+
+// TestRepositoryNamesStayInRoot - None of the operations on names reaching out of the storage root
+// may touch the repository living right next to it
+func TestRepositoryNamesStayInRoot(t *testing.T) {
+	parent := useTemporaryRoot(t)
+
+	outside := filepath.Join(parent, "outside")
+	outsideRepository, err := git.InitRepository(outside, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsideRepository.Free()
+	if err = os.WriteFile(filepath.Join(outside, "victim"), []byte("untouched"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a plain folder behind a link, which git would not take for a repository
+	linked := filepath.Join(parent, "linked")
+	if err = os.Mkdir(linked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(linked, filepath.Join(GRepositoryPrefix, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	createTestRepository(t, "inside")
+	if err = CreateNamespace("team"); err != nil {
+		t.Fatal(err)
+	}
+
+	escapes := []string{
+		"..",
+		"../outside",
+		"a/../../outside",
+		"team/../../outside",
+		outside,
+		"%2e%2e/outside",
+		"..%2foutside",
+		`..\outside`,
+		".gituim",
+		"link",
+		"link/repo",
+	}
+
+	for _, name := range escapes {
+		if _, err := CreateRepository(name, ""); err == nil {
+			t.Errorf("create %q: expected an error", name)
+		}
+		if _, err := CreateRepository(name+"/repo", ""); err == nil {
+			t.Errorf("create %q: expected an error", name+"/repo")
+		}
+		if repository, err := openRepositoryNoSearch(name); err == nil {
+			repository.Free()
+			t.Errorf("open %q: expected an error", name)
+		}
+		if _, err := MoveRepository(name, "team"); err == nil {
+			t.Errorf("move %q: expected an error", name)
+		}
+		if _, err := MoveRepository("inside", name); err == nil {
+			t.Errorf("move into %q: expected an error", name)
+		}
+		if deleted, err := DeleteRepository(name); err == nil && deleted {
+			t.Errorf("delete %q: expected nothing to be deleted", name)
+		}
+		if err := CreateNamespace(name); err == nil {
+			t.Errorf("create namespace %q: expected an error", name)
+		}
+		if err := DeleteNamespace(name); err == nil {
+			t.Errorf("delete namespace %q: expected an error", name)
+		}
+	}
+
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, []string{"linked", "outside", "root"}) {
+		t.Errorf("expected nothing new next to the root, got %v", names)
+	}
+
+	if content, err := os.ReadFile(filepath.Join(outside, "victim")); err != nil || string(content) != "untouched" {
+		t.Errorf("expected the outside repository to be untouched, got %q: %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "HEAD")); err != nil {
+		t.Errorf("expected the outside repository to be untouched: %v", err)
+	}
+	if entries, err := os.ReadDir(linked); err != nil || len(entries) != 0 {
+		t.Errorf("expected the linked folder to stay empty, got %v: %v", entries, err)
+	}
+
+	if _, err := openRepositoryNoSearch("inside"); err != nil {
+		t.Errorf("expected inside to stay in place: %v", err)
+	}
+}
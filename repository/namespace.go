@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+)
+
+// namesMutex - Serializes the changes to the folders of the storage root, so that no name gets taken
+// between the check that it is free and the creation or move claiming it
+var namesMutex sync.Mutex
+
+// Namespace - Group of repositories and nested namespaces, a plain folder of the storage root
+type Namespace struct {
+	Name         string
+	Namespaces   []string
+	Repositories []string
+}
+
+// ListNamespaces - Every namespace, nested ones included
+func ListNamespaces() ([]string, error) {
+	var namespaces []string
+	err := walkNamespace("", func(name string, isRepository bool) {
+		if !isRepository {
+			namespaces = append(namespaces, name)
+		}
+	})
+	return namespaces, err
+}
+
+// GetNamespace - Namespaces and repositories right inside a namespace
+func GetNamespace(name string) (*Namespace, error) {
+	if err := checkNamespace(name); err != nil {
+		return nil, err
+	}
+
+	repositories, namespaces, err := readNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Namespace{Name: name, Namespaces: namespaces, Repositories: repositories}, nil
+}
+
+// CreateNamespace - Create a namespace inside an existing one, or at the root
+func CreateNamespace(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	if err := checkNamespace(path.Dir(name)); err != nil {
+		return err
+	}
+
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+
+	err := os.Mkdir(namePath(name), 0755)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("namespace %s: %w", name, AlreadyExistsError)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create namespace: %w", err)
+	}
+
+	log.Printf("Namespace %s created", name)
+	return nil
+}
+
+// DeleteNamespace - Delete a namespace, only once it is empty
+func DeleteNamespace(name string) error {
+	if name == "" || name == "." {
+		return fmt.Errorf("unable to delete the root namespace: %w", InvalidArgumentError)
+	}
+
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+
+	if err := checkNamespace(name); err != nil {
+		return err
+	}
+
+	repositories, namespaces, err := readNamespace(name)
+	if err != nil {
+		return err
+	}
+	if len(repositories) > 0 || len(namespaces) > 0 {
+		return fmt.Errorf("namespace %s is not empty: %w", name, ConflictError)
+	}
+
+	// folders gituim does not list, such as hidden ones, still keep the namespace from being removed
+	err = os.Remove(namePath(name))
+	if errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("namespace %s is not empty: %w", name, ConflictError)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to delete namespace: %w", err)
+	}
+
+	log.Printf("Namespace %s deleted", name)
+	return nil
+}
+
+// MoveRepository - Move a repository to another namespace, the root when empty, returning its new name.
+// Everything gituim keeps about the repository moves along with it.
+func MoveRepository(repositoryName, namespace string) (string, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return "", err
+	}
+
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+
+	if _, err := openRepository(name); err != nil {
+		return "", handleGitError(err, "unable to open repository")
+	}
+
+	if err = checkNamespace(namespace); err != nil {
+		return "", err
+	}
+
+	target, err := ParseRepositoryName(path.Join(namespace, name.Base()))
+	if err != nil {
+		return "", err
+	}
+	if target == name {
+		return name.String(), nil
+	}
+
+	if _, err = os.Lstat(target.path()); err == nil {
+		return "", fmt.Errorf("%s: %w", target, AlreadyExistsError)
+	}
+
+	if err = os.Rename(name.path(), target.path()); err != nil {
+		return "", fmt.Errorf("unable to move repository: %w", err)
+	}
+
+	log.Printf("Repository %s moved to %s", name, target)
+	publish(Event{Type: RepositoryMoved, Repository: target.String(), Previous: name.String()})
+	return target.String(), nil
+}
+
+// ListNamespaceRepositories - Repositories of a namespace and of the namespaces nested in it,
+// every repository when the namespace is empty
+func ListNamespaceRepositories(namespace string) ([]string, error) {
+	if err := checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	var repositories []string
+	err := walkNamespace(namespace, func(name string, isRepository bool) {
+		if isRepository {
+			repositories = append(repositories, name)
+		}
+	})
+	return repositories, err
+}
+
+// checkNamespace - Make sure a namespace exists and that it is not nested in a repository,
+// the empty name and "." being the root
+func checkNamespace(namespace string) error {
+	if namespace == "" || namespace == "." {
+		return nil
+	}
+
+	if err := checkName(namespace); err != nil {
+		return err
+	}
+
+	// symbolic links are not namespaces, they could lead anywhere out of the storage root
+	for current := namespace; current != "."; current = path.Dir(current) {
+		info, err := os.Lstat(namePath(current))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("namespace %s: %w", current, NotFoundError)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to check namespace: %w", err)
+		}
+		if !info.IsDir() || isRepository(current) {
+			return fmt.Errorf("%s is not a namespace: %w", current, InvalidArgumentError)
+		}
+	}
+	return nil
+}
+
+// walkNamespace - Visit the repositories and namespaces nested in a namespace, depth first
+func walkNamespace(namespace string, visit func(name string, isRepository bool)) error {
+	repositories, namespaces, err := readNamespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, repository := range repositories {
+		visit(repository, true)
+	}
+	for _, nested := range namespaces {
+		visit(nested, false)
+		if err = walkNamespace(nested, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readNamespace - Repositories and namespaces right inside a namespace, skipping folders whose
+// names could not be used such as .git or the data directory
+func readNamespace(namespace string) (repositories, namespaces []string, err error) {
+	directory := GRepositoryPrefix
+	if namespace != "" && namespace != "." {
+		directory = namePath(namespace)
+	}
+
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to list namespace: %w", err)
+	}
+
+	for _, file := range files {
+		name := path.Join(namespace, file.Name())
+		if !file.IsDir() || checkName(name) != nil {
+			continue
+		}
+
+		if isRepository(name) {
+			repositories = append(repositories, name)
+		} else {
+			namespaces = append(namespaces, name)
+		}
+	}
+	return repositories, namespaces, nil
+}
+
+func isRepository(name string) bool {
+	_, err := openRepositoryNoSearch(name)
+	return err == nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDeleteNamespaceNotEmpty(t *testing.T) {
+	useTemporaryRoot(t)
+
+	if err := CreateNamespace("team"); err != nil {
+		t.Fatal(err)
+	}
+	// not listed as a namespace or a repository, but still in the way
+	if err := os.Mkdir(filepath.Join(namePath("team"), ".hidden"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteNamespace("team"); !errors.Is(err, ConflictError) {
+		t.Errorf("expected ConflictError, got %v", err)
+	}
+	if _, err := os.Stat(namePath("team")); err != nil {
+		t.Errorf("expected the namespace to stay: %v", err)
+	}
+}
+
+func TestMoveRepositoryConcurrent(t *testing.T) {
+	useTemporaryRoot(t)
+
+	for _, namespace := range []string{"a", "b", "c"} {
+		if err := CreateNamespace(namespace); err != nil {
+			t.Fatal(err)
+		}
+		createTestRepository(t, namespace+"/repo")
+	}
+
+	// every move competes for the same name, only one may get it
+	var wait sync.WaitGroup
+	errs := make([]error, 3)
+	for i, namespace := range []string{"a", "b", "c"} {
+		wait.Add(1)
+		go func(i int, repositoryName string) {
+			defer wait.Done()
+			_, errs[i] = MoveRepository(repositoryName, "")
+		}(i, namespace+"/repo")
+	}
+	wait.Wait()
+
+	moved := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			moved++
+		case !errors.Is(err, AlreadyExistsError):
+			t.Errorf("expected AlreadyExistsError, got %v", err)
+		}
+	}
+	if moved != 1 {
+		t.Errorf("expected exactly one move, got %d", moved)
+	}
+
+	repositories, err := ListNamespaceRepositories("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repositories) != 3 {
+		t.Errorf("expected every repository to survive, got %v", repositories)
+	}
+}
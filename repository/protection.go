@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// BranchProtection - Rules enforced on every update of the branches matching a pattern.
+// Signed commits are only required to carry a signature, gituim has no keys to verify it with.
+// Restricted rules only let the listed users and teams push, nobody once the last of them is gone.
+type BranchProtection struct {
+	Pattern       string   `json:"pattern"`
+	NoForcePush   bool     `json:"no_force_push"`
+	NoDelete      bool     `json:"no_delete"`
+	LinearHistory bool     `json:"linear_history"`
+	SignedCommits bool     `json:"signed_commits"`
+	Restricted    bool     `json:"restricted"`
+	Users         []string `json:"users"`
+	Teams         []string `json:"teams"`
+}
+
+// Pusher - Whoever updates references, checked against the users and teams allowed to push
+type Pusher struct {
+	User  string
+	Teams []string
+}
+
+// protectionMutex - Serializes the read-modify-write cycles of protection files
+var protectionMutex sync.Mutex
+
+// ListBranchProtections - Branch protection rules of a repository
+func ListBranchProtections(repositoryName string) ([]*BranchProtection, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := openRepository(name); err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	protectionMutex.Lock()
+	defer protectionMutex.Unlock()
+
+	return readProtections(name)
+}
+
+// GetBranchProtection - Branch protection rule of a pattern
+func GetBranchProtection(repositoryName, pattern string) (*BranchProtection, error) {
+	protections, err := ListBranchProtections(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, protection := range protections {
+		if protection.Pattern == pattern {
+			return protection, nil
+		}
+	}
+	return nil, NotFoundError
+}
+
+// SetBranchProtection - Create the rule of a pattern or replace the existing one
+func SetBranchProtection(repositoryName string, protection *BranchProtection) (*BranchProtection, error) {
+	if _, err := path.Match(protection.Pattern, ""); err != nil || protection.Pattern == "" {
+		return nil, fmt.Errorf("invalid branch pattern %q: %w", protection.Pattern, InvalidArgumentError)
+	}
+	protection.Restricted = protection.Restricted || len(protection.Users) > 0 || len(protection.Teams) > 0
+
+	err := updateProtections(repositoryName, func(protections []*BranchProtection) []*BranchProtection {
+		for i, existing := range protections {
+			if existing.Pattern == protection.Pattern {
+				protections[i] = protection
+				return protections
+			}
+		}
+		return append(protections, protection)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Branches %s of %s protected", protection.Pattern, repositoryName)
+	return protection, nil
+}
+
+// DeleteBranchProtection - Lift the protection of the branches matching a pattern
+func DeleteBranchProtection(repositoryName, pattern string) error {
+	found := false
+	err := updateProtections(repositoryName, func(protections []*BranchProtection) []*BranchProtection {
+		for i, existing := range protections {
+			if existing.Pattern == pattern {
+				found = true
+				return append(protections[:i], protections[i+1:]...)
+			}
+		}
+		return protections
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return NotFoundError
+	}
+
+	log.Printf("Protection of branches %s of %s deleted", pattern, repositoryName)
+	return nil
+}
+
+// checkReferenceUpdates - Reject updates breaking the protection of the branches they touch
+func checkReferenceUpdates(repository *git.Repository, repositoryName string, pusher *Pusher, updates ...ReferenceUpdate) error {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	protections, err := readProtections(name)
+	if err != nil || len(protections) == 0 {
+		return err
+	}
+
+	for _, update := range updates {
+		branchName, ok := strings.CutPrefix(update.Name, "refs/heads/")
+		if !ok {
+			continue
+		}
+
+		for _, protection := range protections {
+			if matched, _ := path.Match(protection.Pattern, branchName); !matched {
+				continue
+			}
+			if err = protection.check(repository, pusher, branchName, update); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *BranchProtection) check(repository *git.Repository, pusher *Pusher, branchName string, update ReferenceUpdate) error {
+	rejected := func(reason string) error {
+		return fmt.Errorf("branch %s is protected by %s, %s: %w", branchName, p.Pattern, reason, ProtectedBranchError)
+	}
+
+	if !p.allows(pusher) {
+		return rejected("the pusher is not allowed to update it")
+	}
+
+	if update.New == nil {
+		if p.NoDelete {
+			return rejected("it cannot be deleted")
+		}
+		return nil
+	}
+
+	if p.NoForcePush && update.Old != nil && !update.Old.Equal(update.New) {
+		descendant, err := repository.DescendantOf(update.New, update.Old)
+		if err != nil {
+			return handleGitError(err, "unable to check ancestry")
+		}
+		if !descendant {
+			return rejected("it cannot be force-pushed")
+		}
+	}
+
+	if !p.LinearHistory && !p.SignedCommits {
+		return nil
+	}
+
+	walk, err := repository.Walk()
+	if err != nil {
+		return handleGitError(err, "unable to create revision walker")
+	}
+	defer walk.Free()
+
+	if err = walk.Push(update.New); err != nil {
+		return handleGitError(err, "unable to push revision")
+	}
+	// new branches are only checked for the commits no other branch already has
+	if update.Old != nil {
+		err = walk.Hide(update.Old)
+	} else {
+		err = walk.HideGlob("refs/heads/*")
+	}
+	if err != nil {
+		return handleGitError(err, "unable to hide revision")
+	}
+
+	var violation error
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		if p.LinearHistory && commit.ParentCount() > 1 {
+			violation = rejected(fmt.Sprintf("it requires a linear history but %s is a merge", commit.Id()))
+			return false
+		}
+		if p.SignedCommits {
+			if _, _, err := commit.ExtractSignature(); err != nil {
+				if !git.IsErrorCode(err, git.ErrorCodeNotFound) {
+					violation = handleGitError(err, "unable to read commit signature")
+				} else {
+					violation = rejected(fmt.Sprintf("it requires signed commits but %s is not signed", commit.Id()))
+				}
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return handleGitError(err, "unable to walk commits")
+	}
+	return violation
+}
+
+// allows - Report whether the pusher may update the branches, anyone may unless the rule is restricted
+func (p *BranchProtection) allows(pusher *Pusher) bool {
+	// rules written before restrictions were recorded restrict whenever they list someone
+	if !p.Restricted && len(p.Users) == 0 && len(p.Teams) == 0 {
+		return true
+	}
+	if pusher == nil {
+		return false
+	}
+
+	for _, user := range p.Users {
+		if user == pusher.User {
+			return true
+		}
+	}
+	for _, team := range p.Teams {
+		for _, member := range pusher.Teams {
+			if team == member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// revokeProtections - Remove a user, or a team, from the pushers allowed by the protections of a repository
+func revokeProtections(repositoryName, account string, team bool) error {
+	protections, err := ListBranchProtections(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	listed := false
+	for _, protection := range protections {
+		listed = listed || slices.Contains(protection.accounts(team), account)
+	}
+	if !listed {
+		return nil
+	}
+
+	return updateProtections(repositoryName, func(protections []*BranchProtection) []*BranchProtection {
+		for i, protection := range protections {
+			revoked := *protection
+			revoked.Restricted = protection.Restricted || len(protection.Users) > 0 || len(protection.Teams) > 0
+			if team {
+				revoked.Teams = slices.DeleteFunc(slices.Clone(protection.Teams), func(name string) bool { return name == account })
+			} else {
+				revoked.Users = slices.DeleteFunc(slices.Clone(protection.Users), func(name string) bool { return name == account })
+			}
+			protections[i] = &revoked
+		}
+		return protections
+	})
+}
+
+// accounts - Teams or users allowed to push
+func (p *BranchProtection) accounts(team bool) []string {
+	if team {
+		return p.Teams
+	}
+	return p.Users
+}
+
+func updateProtections(repositoryName string, change func(protections []*BranchProtection) []*BranchProtection) error {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := openRepository(name); err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+
+	protectionMutex.Lock()
+	defer protectionMutex.Unlock()
+
+	protections, err := readProtections(name)
+	if err != nil {
+		return err
+	}
+
+	if err = writeJSONFile(name.dataPath("protections.json"), change(protections)); err != nil {
+		return fmt.Errorf("unable to save branch protections: %w", err)
+	}
+	return nil
+}
+
+func readProtections(name RepositoryName) ([]*BranchProtection, error) {
+	protections := []*BranchProtection{}
+	if _, err := readJSONFile(name.dataPath("protections.json"), &protections); err != nil {
+		return nil, fmt.Errorf("unable to read branch protections: %w", err)
+	}
+	return protections, nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// protectedHistory - Commits of a repository whose stable branch is protected: stable at base, next
+// fast-forwarding it, merge merging other into it and other on an unrelated history
+type protectedHistory struct {
+	base, next, merge, other *git.Oid
+}
+
+func setupProtectedRepository(t *testing.T) protectedHistory {
+	t.Helper()
+
+	useTemporaryRoot(t)
+	createTestRepository(t, "protected")
+	base := testCommit(t, "protected", "stable", time.Time{}, writeFile("README", "a", true))
+	other := testCommit(t, "protected", "other", time.Time{}, writeFile("OTHER", "b", true))
+
+	rules := []*BranchProtection{
+		{Pattern: "stable", NoForcePush: true, NoDelete: true, LinearHistory: true, Users: []string{"alice"}},
+		{Pattern: "signed/*", SignedCommits: true},
+	}
+	for _, rule := range rules {
+		if _, err := SetBranchProtection("protected", rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repository, err := openRepositoryNoSearch("protected")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// commits the branches do not point at yet, as a push would bring them
+	commit := func(message string, parents ...*git.Oid) *git.Oid {
+		var commits []*git.Commit
+		for _, parent := range parents {
+			parentCommit, err := repository.LookupCommit(parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			commits = append(commits, parentCommit)
+		}
+
+		tree, err := commits[0].Tree()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature := NewSignature("Test", "test@example.com", time.Time{})
+		oid, err := repository.CreateCommit("", signature, signature, message, tree, commits...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return oid
+	}
+
+	return protectedHistory{
+		base:  base.Commit,
+		next:  commit("next", base.Commit),
+		merge: commit("merge", base.Commit, other.Commit),
+		other: other.Commit,
+	}
+}
+
+func TestRunHookProtectedBranches(t *testing.T) {
+	history := setupProtectedRepository(t)
+	zero := strings.Repeat("0", 40)
+
+	t.Setenv("GIT_DIR", namePath("protected"))
+	t.Setenv("GITUIM_REPOSITORY", "protected")
+
+	tests := []struct {
+		name     string
+		pusher   string
+		input    string
+		rejected string
+	}{
+		{"fast-forward", "alice", history.base.String() + " " + history.next.String() + " refs/heads/stable", ""},
+		{"pusher not allowed", "bob", history.base.String() + " " + history.next.String() + " refs/heads/stable", "not allowed"},
+		{"anonymous pusher", "", history.base.String() + " " + history.next.String() + " refs/heads/stable", "not allowed"},
+		{"force-push", "alice", history.base.String() + " " + history.other.String() + " refs/heads/stable", "force-pushed"},
+		{"delete", "alice", history.base.String() + " " + zero + " refs/heads/stable", "deleted"},
+		{"merge", "alice", history.base.String() + " " + history.merge.String() + " refs/heads/stable", "linear history"},
+		{"unsigned", "bob", zero + " " + history.next.String() + " refs/heads/signed/x", "signed commits"},
+		{"unprotected", "bob", history.other.String() + " " + history.merge.String() + " refs/heads/other", ""},
+		{"tag", "bob", zero + " " + history.other.String() + " refs/tags/stable", ""},
+	}
+
+	for _, test := range tests {
+		t.Setenv("GITUIM_PUSHER", test.pusher)
+
+		var stderr bytes.Buffer
+		code := RunHook("pre-receive", strings.NewReader(test.input+"\n"), &stderr)
+		if test.rejected == "" && code != 0 {
+			t.Errorf("%s: expected the push to be accepted, got %d: %s", test.name, code, stderr.String())
+		}
+		if test.rejected != "" && (code == 0 || !strings.Contains(stderr.String(), test.rejected)) {
+			t.Errorf("%s: expected the push to be rejected for %q, got %d: %s", test.name, test.rejected, code, stderr.String())
+		}
+	}
+}
+
+func TestBranchProtectionRestricted(t *testing.T) {
+	tests := []struct {
+		name       string
+		protection BranchProtection
+		pusher     *Pusher
+		expected   bool
+	}{
+		{"open", BranchProtection{}, &Pusher{User: "bob"}, true},
+		{"listed user", BranchProtection{Restricted: true, Users: []string{"alice"}}, &Pusher{User: "alice"}, true},
+		{"listed team", BranchProtection{Restricted: true, Teams: []string{"core"}}, &Pusher{User: "bob", Teams: []string{"core"}}, true},
+		{"unlisted", BranchProtection{Restricted: true, Users: []string{"alice"}}, &Pusher{User: "bob"}, false},
+		{"emptied", BranchProtection{Restricted: true}, &Pusher{User: "alice"}, false},
+		{"written before restrictions", BranchProtection{Users: []string{"alice"}}, &Pusher{User: "bob"}, false},
+		{"no pusher", BranchProtection{Restricted: true, Users: []string{"alice"}}, nil, false},
+	}
+
+	for _, test := range tests {
+		if allowed := test.protection.allows(test.pusher); allowed != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, allowed)
+		}
+	}
+}
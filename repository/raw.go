@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// rawSniffLength - Bytes read ahead of serving a blob to tell its type, as many as git looks at
+const rawSniffLength = 8000
+
+// RawBlob - Content of a blob read from the object database as it is served. Seeking only moves the
+// offset, the next read reopening the stream and skipping to it when needed, so ranges of large
+// files are served without holding the files in memory.
+type RawBlob struct {
+	Oid      *git.Oid
+	Size     int64
+	IsBinary bool
+	// Head - First bytes of the content, enough to sniff its type
+	Head []byte
+
+	odb      *git.Odb
+	stream   *git.OdbReadStream
+	object   *git.OdbObject
+	position int64
+	offset   int64
+}
+
+// OpenRawBlob - Open the blob at path in a revision for reading, to be closed by the caller
+func OpenRawBlob(repositoryName, revision, path string) (*RawBlob, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	resolved, err := lookupEntry(repository, revision, path)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.entry == nil || resolved.entry.Type != git.ObjectBlob {
+		return nil, NotFoundError
+	}
+
+	odb, err := repository.Odb()
+	if err != nil {
+		return nil, handleGitError(err, "unable to open object database")
+	}
+
+	size, _, err := odb.ReadHeader(resolved.entry.Id)
+	if err != nil {
+		odb.Free()
+		return nil, handleGitError(err, "unable to read blob header")
+	}
+
+	blob := &RawBlob{Oid: resolved.entry.Id, Size: int64(size), odb: odb}
+	blob.Head = make([]byte, min(blob.Size, rawSniffLength))
+	if _, err = io.ReadFull(blob, blob.Head); err != nil {
+		blob.Close()
+		return nil, err
+	}
+	// like git, a NUL byte among the first bytes makes the content binary
+	blob.IsBinary = bytes.IndexByte(blob.Head, 0) >= 0
+
+	blob.offset = 0
+	return blob, nil
+}
+
+func (b *RawBlob) Read(p []byte) (int, error) {
+	if b.offset >= b.Size {
+		return 0, io.EOF
+	}
+
+	if b.stream == nil && b.object == nil || b.stream != nil && b.offset < b.position {
+		if err := b.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if b.object != nil {
+		n := copy(p, b.object.Data()[b.offset:])
+		b.offset += int64(n)
+		return n, nil
+	}
+
+	if b.offset > b.position {
+		if _, err := io.CopyN(io.Discard, streamReader{b}, b.offset-b.position); err != nil {
+			return 0, fmt.Errorf("unable to skip to offset %d: %w", b.offset, err)
+		}
+	}
+
+	n, err := streamReader{b}.Read(p)
+	b.offset = b.position
+	return n, err
+}
+
+func (b *RawBlob) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.Size
+	default:
+		return 0, fmt.Errorf("invalid whence %d: %w", whence, InvalidArgumentError)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	b.offset = offset
+	return offset, nil
+}
+
+func (b *RawBlob) Close() error {
+	if b.stream != nil {
+		b.stream.Free()
+		b.stream = nil
+	}
+	if b.object != nil {
+		b.object.Free()
+		b.object = nil
+	}
+	b.odb.Free()
+	return nil
+}
+
+// open - (Re)open the read stream at the start of the content. Pack files cannot be streamed from,
+// their objects are read whole instead.
+func (b *RawBlob) open() error {
+	if b.stream != nil {
+		b.stream.Free()
+		b.stream = nil
+	}
+
+	stream, err := b.odb.NewReadStream(b.Oid)
+	if err != nil {
+		object, err := b.odb.Read(b.Oid)
+		if err != nil {
+			return handleGitError(err, "unable to read blob")
+		}
+		b.object = object
+		return nil
+	}
+
+	b.stream = stream
+	b.position = 0
+	return nil
+}
+
+// streamReader - Reads of the stream of a raw blob, keeping track of its position
+type streamReader struct {
+	blob *RawBlob
+}
+
+func (r streamReader) Read(p []byte) (int, error) {
+	// libgit2 fills the slice up to its capacity
+	n, err := r.blob.stream.Read(p[:len(p):len(p)])
+	r.blob.position += int64(n)
+	return n, err
+}
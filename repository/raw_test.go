@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestOpenRawBlob(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+
+	content := bytes.Repeat([]byte("0123456789"), 3000)
+	testCommit(t, "repo", "main", time.Time{}, writeFile("large.txt", string(content), true), writeFile("bin", "a\x00b", true))
+
+	blob, err := OpenRawBlob("repo", "main", "large.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blob.Close()
+
+	if blob.Size != int64(len(content)) || blob.IsBinary || len(blob.Head) != rawSniffLength {
+		t.Errorf("unexpected blob size %d, binary %v and head of %d bytes", blob.Size, blob.IsBinary, len(blob.Head))
+	}
+
+	all, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(all, content) {
+		t.Errorf("expected the whole content, got %d bytes", len(all))
+	}
+
+	// forward and backward seeks, as ranges need
+	for _, start := range []int64{25000, 10, 29990, 0} {
+		if _, err = blob.Seek(start, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		part := make([]byte, 10)
+		if _, err = io.ReadFull(blob, part); err != nil {
+			t.Fatalf("at %d: %v", start, err)
+		}
+		if !bytes.Equal(part, content[start:start+10]) {
+			t.Errorf("at %d: expected %q, got %q", start, content[start:start+10], part)
+		}
+	}
+
+	if end, err := blob.Seek(0, io.SeekEnd); err != nil || end != blob.Size {
+		t.Errorf("expected the end at %d, got %d: %v", blob.Size, end, err)
+	}
+	if n, err := blob.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("expected EOF at the end, got %d: %v", n, err)
+	}
+
+	binary, err := OpenRawBlob("repo", "main", "bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer binary.Close()
+	if !binary.IsBinary {
+		t.Error("expected a binary blob")
+	}
+
+	if _, err = OpenRawBlob("repo", "main", "missing"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError, got %v", err)
+	}
+}
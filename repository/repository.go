@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
 	git "github.com/libgit2/git2go/v34"
 )
@@ -11,74 +12,128 @@ import (
 var (
 	GCurrentWorkingDirectory = getCurrentWorkingDirectory()
 	GRepositoryPrefix        = getEnvOrDefault("GITUIM_REPOSITORY_PREFIX", GCurrentWorkingDirectory)
+	GDataDirectory           = getEnvOrDefault("GITUIM_DATA_DIRECTORY", filepath.Join(GRepositoryPrefix, ".gituim"))
 )
 
 type Repository struct {
-	Repository string
-	IsBare     bool
+	Repository    string
+	IsBare        bool
+	Description   string
+	DefaultBranch string
+	Topics        []string
+	Properties    map[string]string
 }
 
-// ListRepositories - Lists current path repositories, ignores `.git` folders
+// ListRepositories - Lists current path repositories, the ones in namespaces included, ignores `.git` folders
 func ListRepositories() ([]string, error) {
-	files, err := os.ReadDir(GRepositoryPrefix)
+	return ListNamespaceRepositories("")
+}
 
+// CreateRepository - Creates a new bare repository in the current folder. admin, when not empty, is
+// granted the admin role before the repository is announced, the repository being removed again
+// when that fails so that none is left without an administrator.
+func CreateRepository(repositoryName, admin string) (bool, error) {
+	name, err := ParseRepositoryName(repositoryName)
 	if err != nil {
-		return nil, fmt.Errorf("unable to list current directory: %w", err)
+		return false, err
 	}
 
-	var repositories []string
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
 
-	for _, file := range files {
-		if file.IsDir() && file.Name() != ".git" {
-			_, err := openRepositoryNoSearch(file.Name())
-			if err == nil {
-				repositories = append(repositories, file.Name())
-			}
-		}
+	if err = checkNamespace(name.Namespace()); err != nil {
+		return false, err
 	}
 
-	return repositories, nil
-}
+	// never turn a namespace, or anything else already there, into a repository
+	if _, err = os.Lstat(name.path()); err == nil {
+		return false, fmt.Errorf("%s: %w", name, AlreadyExistsError)
+	}
 
-// CreateRepository - Creates a new bare repository in the current folder
-func CreateRepository(repositoryName string) (bool, error) {
-	_, err := git.InitRepository(getRepositoryPath(repositoryName), true)
+	_, err = git.InitRepository(name.path(), true)
 
 	if err != nil {
 		return false, fmt.Errorf("unable to create repository: %w", err)
 	}
 
+	if admin != "" {
+		if _, err = SetRepositoryUserRole(repositoryName, admin, RoleAdmin); err != nil {
+			if removeErr := os.RemoveAll(name.path()); removeErr != nil {
+				log.Printf("unable to remove %s after failing to grant its admin role: %v", repositoryName, removeErr)
+			}
+			return false, err
+		}
+	}
+
 	log.Printf("Repository %s created", repositoryName)
+	publish(Event{Type: RepositoryCreated, Repository: repositoryName})
 	return true, nil
 }
 
 // DeleteRepository - Delete a repository
 func DeleteRepository(repositoryName string) (bool, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return false, err
+	}
+
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
 
-	// check if the directory exists first
-	if _, err := os.Stat(getRepositoryPath(repositoryName)); os.IsNotExist(err) {
-		return false, nil
+	// only ever remove actual repositories
+	if _, err := openRepository(name); err != nil {
+		if git.IsErrorCode(err, git.ErrorCodeNotFound) {
+			return false, nil
+		}
+		return false, handleGitError(err, "unable to open repository")
 	}
 
-	err := os.RemoveAll(getRepositoryPath(repositoryName))
+	err = os.RemoveAll(name.path())
 
 	if err != nil {
 		return false, fmt.Errorf("unable to delete repository: %w", err)
 	}
 
 	log.Printf("Repository %s deleted", repositoryName)
+	publish(Event{Type: RepositoryDeleted, Repository: repositoryName})
 	return true, nil
 }
 
-// GetRepositoryInfo - List repository information
+// GetRepositoryInfo - List repository information and metadata
 func GetRepositoryInfo(repositoryName string) (*Repository, error) {
-	repository, err := openRepositoryNoSearch(repositoryName)
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := openRepository(name)
 	if err != nil {
 		return nil, handleGitError(err, "unable to open repository")
 	}
 
+	description, err := getDescription(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultBranch, err := getDefaultBranch(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataMutex.Lock()
+	metadata, err := readMetadata(name)
+	metadataMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Repository{
-		Repository: repositoryName,
-		IsBare:     repository.IsBare(),
+		Repository:    repositoryName,
+		IsBare:        repository.IsBare(),
+		Description:   description,
+		DefaultBranch: defaultBranch,
+		Topics:        metadata.Topics,
+		Properties:    metadata.Properties,
 	}, nil
 }
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+const (
+	DefaultSearchLimit = 100
+	MaxSearchLimit     = 1000
+	MaxSearchContext   = 10
+
+	// maxSearchFileSize - Larger blobs are skipped, they are rarely source files
+	maxSearchFileSize = 1 << 20
+)
+
+type SearchOptions struct {
+	Query         string
+	Regex         bool
+	CaseSensitive bool
+	Revision      string
+	Paths         []string
+	Context       int
+	Limit         int
+}
+
+type SearchResult struct {
+	Tree      *git.Oid
+	Files     []*SearchFile
+	Truncated bool
+}
+
+type SearchFile struct {
+	Path    string
+	Oid     *git.Oid
+	Matches []*SearchMatch
+}
+
+type SearchMatch struct {
+	Line    int
+	Column  int
+	Length  int
+	Content string
+	Before  []string
+	After   []string
+}
+
+// SearchCode - Search the contents of the files in the tree of a revision, like `git grep`.
+// Column is the 1 based byte offset of the match in its line, binary and large files are skipped.
+func SearchCode(repositoryName string, options SearchOptions) (*SearchResult, error) {
+	pattern, err := CompileSearchPattern(options.Query, options.Regex, options.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, glob := range options.Paths {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid path glob %q: %w", glob, InvalidArgumentError)
+		}
+	}
+
+	limit := min(options.Limit, MaxSearchLimit)
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	context := options.Context
+	if context > MaxSearchContext {
+		context = MaxSearchContext
+	}
+
+	result := &SearchResult{}
+	found := 0
+	result.Tree, err = WalkFiles(repositoryName, options.Revision, func(file *File) (bool, error) {
+		if !MatchesPathGlobs(file.Path, options.Paths) {
+			return true, nil
+		}
+
+		contents, err := file.Read()
+		if err != nil {
+			return false, err
+		}
+		if contents == nil {
+			return true, nil
+		}
+
+		matches, truncated := SearchLines(contents, pattern, context, limit-found)
+		if len(matches) > 0 {
+			result.Files = append(result.Files, &SearchFile{Path: file.Path, Oid: file.Oid, Matches: matches})
+			found += len(matches)
+		}
+
+		result.Truncated = truncated
+		return !truncated, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// File - Regular file met walking a tree
+type File struct {
+	Path string
+	Oid  *git.Oid
+	Size uint64
+
+	repository *git.Repository
+}
+
+// Read - Load the contents of the file, nil when it is binary or too large to be searched
+func (f *File) Read() ([]byte, error) {
+	if f.Size > maxSearchFileSize {
+		return nil, nil
+	}
+
+	blob, err := f.repository.LookupBlob(f.Oid)
+	if err != nil {
+		return nil, handleGitError(err, "unable to lookup blob")
+	}
+	if blob.IsBinary() {
+		return nil, nil
+	}
+
+	return blob.Contents(), nil
+}
+
+// WalkFiles - Call fn for every regular file in the tree of a revision, HEAD when empty, until it returns false.
+// Returns the id of the walked tree.
+func WalkFiles(repositoryName, revision string, fn func(file *File) (bool, error)) (*git.Oid, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	tree, err := revparseTree(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	odb, err := repository.Odb()
+	if err != nil {
+		return nil, handleGitError(err, "unable to open object database")
+	}
+
+	var walkErr error
+	err = tree.Walk(func(root string, entry *git.TreeEntry) error {
+		if entry.Filemode != git.FilemodeBlob && entry.Filemode != git.FilemodeBlobExecutable {
+			return nil
+		}
+
+		size, _, err := odb.ReadHeader(entry.Id)
+		if err != nil {
+			walkErr = handleGitError(err, "unable to read object header")
+			return walkErr
+		}
+
+		more, err := fn(&File{Path: root + entry.Name, Oid: entry.Id, Size: size, repository: repository})
+		if err != nil {
+			walkErr = err
+			return err
+		}
+		if !more {
+			return errStopWalk
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	if err != nil && err != errStopWalk {
+		return nil, handleGitError(err, "unable to walk through the tree")
+	}
+
+	return tree.Id(), nil
+}
+
+// LookupTreeId - Id of the tree of a revision, HEAD when empty
+func LookupTreeId(repositoryName, revision string) (*git.Oid, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	tree, err := revparseTree(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Id(), nil
+}
+
+// revparseTree - Resolve a revision, HEAD when empty, and peel it to a tree
+func revparseTree(repository *git.Repository, revision string) (*git.Tree, error) {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	object, err := repository.RevparseSingle(revision)
+	if err != nil {
+		return nil, handleGitError(err, "unable to rev parse revision")
+	}
+
+	peeled, err := object.Peel(git.ObjectTree)
+	if err != nil {
+		return nil, handleGitError(err, "unable to peel revision to a tree")
+	}
+
+	tree, err := peeled.AsTree()
+	if err != nil {
+		return nil, handleGitError(err, "unable to get tree")
+	}
+	return tree, nil
+}
+
+// CompileSearchPattern - Regular expression for a query, quoted unless it is a regex itself
+func CompileSearchPattern(query string, isRegex, caseSensitive bool) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search query: %w", InvalidArgumentError)
+	}
+
+	if !isRegex {
+		query = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		query = "(?i)" + query
+	}
+
+	pattern, err := regexp.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search query: %v: %w", err, InvalidArgumentError)
+	}
+	return pattern, nil
+}
+
+// SearchLines - Matches of pattern in contents line by line, at most limit of them.
+// The second result reports whether more matches were left out.
+func SearchLines(contents []byte, pattern *regexp.Regexp, context, limit int) ([]*SearchMatch, bool) {
+	lines := strings.Split(string(bytes.TrimSuffix(contents, []byte("\n"))), "\n")
+
+	var matches []*SearchMatch
+	for number, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+
+		for _, location := range pattern.FindAllStringIndex(line, -1) {
+			// empty matches of patterns like `a*` carry no information
+			if location[0] == location[1] {
+				continue
+			}
+			if len(matches) == limit {
+				return matches, true
+			}
+
+			matches = append(matches, &SearchMatch{
+				Line:    number + 1,
+				Column:  location[0] + 1,
+				Length:  location[1] - location[0],
+				Content: line,
+				Before:  contextLines(lines, number-context, number),
+				After:   contextLines(lines, number+1, number+1+context),
+			})
+		}
+	}
+
+	return matches, false
+}
+
+func contextLines(lines []string, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+	if from >= to {
+		return nil
+	}
+
+	context := make([]string, 0, to-from)
+	for _, line := range lines[from:to] {
+		context = append(context, strings.TrimSuffix(line, "\r"))
+	}
+	return context
+}
+
+// MatchesPathGlobs - Report whether a file path matches one of the globs, every path matching when there are none.
+// Globs without a slash match the file name in any directory and a trailing /** matches a whole directory.
+func MatchesPathGlobs(filePath string, globs []string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+
+	for _, glob := range globs {
+		glob = strings.Trim(glob, "/")
+
+		if directory, ok := strings.CutSuffix(glob, "/**"); ok {
+			if filePath == directory || strings.HasPrefix(filePath, directory+"/") {
+				return true
+			}
+			continue
+		}
+
+		target := filePath
+		if !strings.Contains(glob, "/") {
+			target = path.Base(filePath)
+		}
+
+		if matched, _ := path.Match(glob, target); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchCodeLimit(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	lines := strings.Repeat("needle\n", MaxSearchLimit+1)
+	testCommit(t, "repo", "main", time.Time{}, writeFile("haystack", lines, true))
+
+	tests := []struct {
+		limit    int
+		expected int
+	}{
+		{0, DefaultSearchLimit},
+		{10, 10},
+		{MaxSearchLimit + 1, MaxSearchLimit},
+		{10 * MaxSearchLimit, MaxSearchLimit},
+	}
+
+	for _, test := range tests {
+		result, err := SearchCode("repo", SearchOptions{Query: "needle", Revision: "main", Limit: test.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Files) != 1 || len(result.Files[0].Matches) != test.expected || !result.Truncated {
+			t.Errorf("limit %d: expected %d truncated matches, got %+v", test.limit, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTemporaryRoot - Point the storage root and the data directory at a fresh temporary folder for
+// the length of a test, returning the folder holding the root so tests can look outside of it
+func useTemporaryRoot(t *testing.T) string {
+	t.Helper()
+
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	previousRoot, previousData := GRepositoryPrefix, GDataDirectory
+	GRepositoryPrefix = root
+	GDataDirectory = filepath.Join(root, ".gituim")
+	t.Cleanup(func() {
+		GRepositoryPrefix, GDataDirectory = previousRoot, previousData
+	})
+
+	return parent
+}
+
+// createTestRepository - Create a repository of the temporary root, failing the test otherwise
+func createTestRepository(t *testing.T, repositoryName string) {
+	t.Helper()
+
+	if _, err := CreateRepository(repositoryName, ""); err != nil {
+		t.Fatalf("unable to create %s: %v", repositoryName, err)
+	}
+}
+
+// testCommit - Commit changes on a branch, authored and committed at when
+func testCommit(t *testing.T, repositoryName, branch string, when time.Time, changes ...FileChange) *Commit {
+	t.Helper()
+
+	commit, err := CreateCommit(repositoryName, CommitOptions{
+		Branch:  branch,
+		Author:  NewSignature("Test", "test@example.com", when),
+		Message: "test commit\n",
+		Changes: changes,
+	})
+	if err != nil {
+		t.Fatalf("unable to commit on %s: %v", branch, err)
+	}
+	return commit
+}
+
+// writeFile - Change creating or replacing a file
+func writeFile(path, content string, create bool) FileChange {
+	action := FileUpdate
+	if create {
+		action = FileCreate
+	}
+	return FileChange{Action: action, Path: path, Content: []byte(content)}
+}
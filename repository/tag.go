@@ -1,5 +1,12 @@
 package repository
 
+import (
+	"fmt"
+	"log"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
 // ListRepositoryTags - list all tags in the repository
 func ListRepositoryTags(repositoryName string) ([]string, error) {
 	repository, err := openRepositoryNoSearch(repositoryName)
@@ -14,3 +21,83 @@ func ListRepositoryTags(repositoryName string) ([]string, error) {
 
 	return tags, nil
 }
+
+// CreateTag - Tag the object a revision resolves to, annotated when a message is given
+func CreateTag(repositoryName, tagName, revision, message string, tagger *git.Signature) (*Tag, error) {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return nil, handleGitError(err, "unable to open repository")
+	}
+
+	object, err := repository.RevparseSingle(revision)
+	if err != nil {
+		return nil, handleGitError(err, "unable to rev parse revision")
+	}
+
+	target, err := asObjecter(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagId *git.Oid
+	if message != "" {
+		if tagger == nil {
+			return nil, fmt.Errorf("annotated tags need a tagger: %w", InvalidArgumentError)
+		}
+		tagId, err = repository.Tags.Create(tagName, target, tagger, message)
+	} else {
+		tagId, err = repository.Tags.CreateLightweight(tagName, target, false)
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to create tag")
+	}
+
+	log.Printf("Tag %s created in %s at %s", tagName, repositoryName, object.Id())
+	publishReferenceUpdate(repositoryName, "refs/tags/"+tagName, nil, tagId)
+	return LookupTag(repositoryName, tagName)
+}
+
+// DeleteTag - Delete a tag
+func DeleteTag(repositoryName, tagName string) error {
+	repository, err := openRepositoryNoSearch(repositoryName)
+	if err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+
+	reference, err := repository.References.Lookup("refs/tags/" + tagName)
+	if err != nil {
+		return handleGitError(err, "unable to lookup tag")
+	}
+
+	err = repository.Tags.Remove(tagName)
+	if err != nil {
+		return handleGitError(err, "unable to delete tag")
+	}
+
+	log.Printf("Tag %s deleted from %s", tagName, repositoryName)
+	publishReferenceUpdate(repositoryName, reference.Name(), reference.Target(), nil)
+	return nil
+}
+
+// asObjecter - Concrete commit, tree, blob or tag behind a generic object
+func asObjecter(object *git.Object) (git.Objecter, error) {
+	var objecter git.Objecter
+	var err error
+	switch object.Type() {
+	case git.ObjectCommit:
+		objecter, err = object.AsCommit()
+	case git.ObjectTree:
+		objecter, err = object.AsTree()
+	case git.ObjectBlob:
+		objecter, err = object.AsBlob()
+	case git.ObjectTag:
+		objecter, err = object.AsTag()
+	default:
+		return nil, fmt.Errorf("unsupported object type %s", object.Type())
+	}
+	if err != nil {
+		return nil, handleGitError(err, "unable to get object")
+	}
+
+	return objecter, nil
+}
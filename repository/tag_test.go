@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+func TestCreateTag(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	commit := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+
+	var updates []ReferenceUpdate
+	Subscribe(func(event Event) {
+		if event.Repository == "repo" && event.Type == ReferencesUpdated {
+			updates = append(updates, event.Updates...)
+		}
+	})
+
+	lightweight, err := CreateTag("repo", "v1", "main", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lightweight.Annotated || !lightweight.Oid.Equal(commit.Commit) || !lightweight.Commit.Commit.Equal(commit.Commit) {
+		t.Errorf("expected a lightweight tag of %s, got %+v", commit.Commit, lightweight)
+	}
+
+	tagger := NewSignature("Tagger", "tagger@example.com", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	annotated, err := CreateTag("repo", "v2", "main", "release 2\n", tagger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !annotated.Annotated || annotated.Oid.Equal(commit.Commit) || !annotated.Target.Equal(commit.Commit) ||
+		annotated.Message != "release 2\n" || annotated.Tagger.Email != "tagger@example.com" {
+		t.Errorf("expected an annotated tag of %s, got %+v", commit.Commit, annotated)
+	}
+
+	if len(updates) != 2 || updates[0].Name != "refs/tags/v1" || !updates[0].New.Equal(commit.Commit) ||
+		updates[1].Name != "refs/tags/v2" || !updates[1].New.Equal(annotated.Oid) {
+		t.Errorf("expected the creation of both tags, got %+v", updates)
+	}
+
+	if _, err = CreateTag("repo", "v1", "main", "", nil); !errors.Is(err, AlreadyExistsError) {
+		t.Errorf("expected AlreadyExistsError for an existing tag, got %v", err)
+	}
+	if _, err = CreateTag("repo", "v3", "main", "message", nil); !errors.Is(err, InvalidArgumentError) {
+		t.Errorf("expected InvalidArgumentError for an annotated tag without tagger, got %v", err)
+	}
+	if _, err = CreateTag("repo", "v3", "missing", "", nil); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError for a missing revision, got %v", err)
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	tag, err := CreateTag("repo", "v1", "main", "release\n", NewSignature("Tagger", "tagger@example.com", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []ReferenceUpdate
+	Subscribe(func(event Event) {
+		if event.Repository == "repo" && event.Type == ReferencesUpdated {
+			updates = append(updates, event.Updates...)
+		}
+	})
+
+	if err = DeleteTag("repo", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LookupTag("repo", "v1"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected the tag to be deleted, got %v", err)
+	}
+	if len(updates) != 1 || updates[0].Name != "refs/tags/v1" || !updates[0].Old.Equal(tag.Oid) || updates[0].New != nil {
+		t.Errorf("expected the deletion of the tag object, got %+v", updates)
+	}
+
+	if err = DeleteTag("repo", "v1"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError for a missing tag, got %v", err)
+	}
+
+	tags, err := ListRepositoryTags("repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("expected no tags left, got %v", tags)
+	}
+}
+
+func TestLookupTagPeeling(t *testing.T) {
+	useTemporaryRoot(t)
+	createTestRepository(t, "repo")
+	commit := testCommit(t, "repo", "main", time.Time{}, writeFile("README", "a", true))
+	tagger := NewSignature("Tagger", "tagger@example.com", time.Time{})
+
+	tags := []struct {
+		name, revision, message string
+	}{
+		{"lightweight", "main", ""},
+		{"annotated", "main", "outer\n"},
+		{"tree", "main^{tree}", "tree\n"},
+		{"blob", "main:README", "blob\n"},
+		{"nested", "annotated", "nested\n"},
+		{"lightweight-annotated", "annotated", ""},
+	}
+	for _, tag := range tags {
+		if _, err := CreateTag("repo", tag.name, tag.revision, tag.message, tagger); err != nil {
+			t.Fatalf("%s: %v", tag.name, err)
+		}
+	}
+
+	annotated, err := LookupTag("repo", "annotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		annotated  bool
+		oid        *git.Oid
+		message    string
+		targetType git.ObjectType
+	}{
+		{"lightweight", false, commit.Commit, "", git.ObjectCommit},
+		{"annotated", true, annotated.Oid, "outer\n", git.ObjectCommit},
+		{"tree", true, nil, "tree\n", git.ObjectTree},
+		{"blob", true, nil, "blob\n", git.ObjectBlob},
+		{"nested", true, nil, "nested\n", git.ObjectCommit},
+		{"lightweight-annotated", true, annotated.Oid, "outer\n", git.ObjectCommit},
+	}
+	for _, test := range tests {
+		tag, err := LookupTag("repo", test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if tag.Annotated != test.annotated || tag.Message != test.message || tag.TargetType != test.targetType {
+			t.Errorf("%s: unexpected tag %+v", test.name, tag)
+		}
+		if test.oid != nil && !tag.Oid.Equal(test.oid) {
+			t.Errorf("%s: expected the tag at %s, got %s", test.name, test.oid, tag.Oid)
+		}
+		if test.annotated && tag.Tagger.Email != "tagger@example.com" {
+			t.Errorf("%s: expected the tagger, got %+v", test.name, tag.Tagger)
+		}
+		// only tags peeling to commits carry one
+		if (test.targetType == git.ObjectCommit) != (tag.Commit != nil) {
+			t.Errorf("%s: unexpected commit %+v", test.name, tag.Commit)
+		}
+		if test.targetType == git.ObjectCommit && !tag.Target.Equal(commit.Commit) {
+			t.Errorf("%s: expected the tag to peel to %s, got %s", test.name, commit.Commit, tag.Target)
+		}
+	}
+
+	if _, err = LookupTag("repo", "missing"); !errors.Is(err, NotFoundError) {
+		t.Errorf("expected NotFoundError for a missing tag, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Service - git smart HTTP service
+type Service string
+
+const (
+	UploadPack  Service = "git-upload-pack"
+	ReceivePack Service = "git-receive-pack"
+)
+
+// IsValid - Report whether the service is one served over smart HTTP
+func (s Service) IsValid() bool {
+	return s == UploadPack || s == ReceivePack
+}
+
+// AdvertiseReferences - Write the reference advertisement of a service
+func AdvertiseReferences(repositoryName string, service Service, protocol string, w io.Writer) error {
+	return runService(repositoryName, service, protocol, nil, true, nil, w)
+}
+
+// ServeService - Run a stateless RPC exchange of a service, reading the client request from r.
+// Pushes are checked against the protected branches of the repository on behalf of pusher.
+func ServeService(repositoryName string, service Service, protocol string, pusher *Pusher, r io.Reader, w io.Writer) error {
+	return runService(repositoryName, service, protocol, pusher, false, r, w)
+}
+
+func runService(repositoryName string, service Service, protocol string, pusher *Pusher, advertise bool, r io.Reader, w io.Writer) error {
+	if !service.IsValid() {
+		return fmt.Errorf("unsupported service %s", service)
+	}
+
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	// make sure we only ever hand actual repositories to git
+	if _, err := openRepository(name); err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+
+	// pushes bypass libgit2, gituim runs as their hooks to check the references they update, then
+	// to collect the ones git actually moved into a file read back once the push is done
+	pushing := service == ReceivePack && !advertise
+	var args []string
+	var updates *os.File
+	if pushing {
+		hooks, err := hooksPath()
+		if err != nil {
+			return err
+		}
+		args = append(args, "-c", "core.hooksPath="+hooks)
+
+		updates, err = os.CreateTemp("", "gituim-push-*")
+		if err != nil {
+			return fmt.Errorf("unable to create push updates file: %w", err)
+		}
+		defer os.Remove(updates.Name())
+		defer updates.Close()
+	}
+
+	args = append(args, strings.TrimPrefix(string(service), "git-"), "--stateless-rpc")
+	if advertise {
+		args = append(args, "--advertise-refs")
+	}
+	args = append(args, name.path())
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Env = os.Environ()
+	if protocol != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+protocol)
+	}
+	if pushing {
+		cmd.Env = append(cmd.Env, "GITUIM_REPOSITORY="+repositoryName, "GITUIM_PUSH_UPDATES="+updates.Name())
+		if pusher != nil {
+			cmd.Env = append(cmd.Env, "GITUIM_PUSHER="+pusher.User, "GITUIM_PUSHER_TEAMS="+strings.Join(pusher.Teams, ","))
+		}
+	}
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+
+	// a failed push may still have updated some of the references
+	if pushing {
+		publishPush(repositoryName, updates)
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("unable to run %s: %w: %s", service, runErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -1,16 +1,27 @@
 package repository
 
 import (
-	"fmt"
+	"encoding/json"
 	"os"
+	"path/filepath"
+	"time"
 
 	git "github.com/libgit2/git2go/v34"
 )
 
-// Open a repository with Bare and NoSearch flags enabled
+// Open a repository with Bare and NoSearch flags enabled, once its name is checked
 func openRepositoryNoSearch(repositoryName string) (*git.Repository, error) {
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+	return openRepository(name)
+}
+
+// Open a repository with Bare and NoSearch flags enabled
+func openRepository(name RepositoryName) (*git.Repository, error) {
 	flags := git.RepositoryOpenBare | git.RepositoryOpenNoSearch
-	return git.OpenRepositoryExtended(getRepositoryPath(repositoryName), flags, "")
+	return git.OpenRepositoryExtended(name.path(), flags, "")
 }
 
 func getEnvOrDefault(key, fallbackValue string) string {
@@ -28,6 +39,70 @@ func getCurrentWorkingDirectory() string {
 	}
 }
 
-func getRepositoryPath(repositoryName string) string {
-	return fmt.Sprintf("%s/%s", GRepositoryPrefix, repositoryName)
+// NewSignature - Signature for objects created through gituim, dated now when when is zero
+func NewSignature(name, email string, when time.Time) *git.Signature {
+	if when.IsZero() {
+		when = time.Now()
+	}
+	return &git.Signature{Name: name, Email: email, When: when}
+}
+
+// ReadDataFile - Decode a JSON file of the data directory, reporting false when it does not exist
+func ReadDataFile(name string, value interface{}) (bool, error) {
+	return readJSONFile(filepath.Join(GDataDirectory, name), value)
+}
+
+// WriteDataFile - Encode value to a JSON file of the data directory, replacing it atomically
+func WriteDataFile(name string, value interface{}) error {
+	return writeJSONFile(filepath.Join(GDataDirectory, name), value)
+}
+
+// readJSONFile - Decode a JSON file into value, reporting false when it does not exist
+func readJSONFile(path string, value interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, value)
+}
+
+// writeJSONFile - Encode value to a JSON file, replacing it atomically
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err = temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), path)
+}
+
+// removeFile - Remove a file, succeeding when it does not exist
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
 }
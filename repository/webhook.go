@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxWebhookDeliveries - Deliveries kept in the log of each webhook, the oldest are dropped first
+const maxWebhookDeliveries = 50
+
+type Webhook struct {
+	Id      string      `json:"id"`
+	URL     string      `json:"url"`
+	Secret  string      `json:"secret"`
+	Events  []EventType `json:"events"`
+	Created time.Time   `json:"created"`
+}
+
+type WebhookDelivery struct {
+	Id         string        `json:"id"`
+	Event      EventType     `json:"event"`
+	Repository string        `json:"repository"`
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"status_code"`
+	Error      string        `json:"error"`
+	Duration   time.Duration `json:"duration"`
+	Delivered  time.Time     `json:"delivered"`
+}
+
+// webhooksMutex - Serializes the read-modify-write cycles of webhook files
+var webhooksMutex sync.Mutex
+
+// Wants - Report whether the webhook subscribed to an event, no events meaning all of them
+func (h *Webhook) Wants(event EventType) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+
+	for _, wanted := range h.Events {
+		if wanted == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ListWebhooks - Webhooks of a repository, the global ones when the repository name is empty
+func ListWebhooks(repositoryName string) ([]*Webhook, error) {
+	if err := checkWebhookScope(repositoryName); err != nil {
+		return nil, err
+	}
+
+	webhooksMutex.Lock()
+	defer webhooksMutex.Unlock()
+
+	return readWebhooks(repositoryName)
+}
+
+// GetWebhook - Lookup a webhook of a repository, global when the repository name is empty
+func GetWebhook(repositoryName, id string) (*Webhook, error) {
+	webhooks, err := ListWebhooks(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Id == id {
+			return webhook, nil
+		}
+	}
+	return nil, NotFoundError
+}
+
+// CreateWebhook - Register a webhook called on events of a repository, or of every repository when
+// the repository name is empty. Repository creation and deletion only reach global webhooks, the
+// webhooks of a repository being created and deleted along with it.
+func CreateWebhook(repositoryName, target, secret string, events []EventType) (*Webhook, error) {
+	if err := checkWebhookScope(repositoryName); err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url %q: %w", target, InvalidArgumentError)
+	}
+
+	for _, event := range events {
+		if !event.IsValid() {
+			return nil, fmt.Errorf("unknown event %q: %w", event, InvalidArgumentError)
+		}
+		if repositoryName != "" && (event == RepositoryCreated || event == RepositoryDeleted) {
+			return nil, fmt.Errorf("event %s only reaches global webhooks: %w", event, InvalidArgumentError)
+		}
+	}
+
+	id, err := randomId()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooksMutex.Lock()
+	defer webhooksMutex.Unlock()
+
+	webhooks, err := readWebhooks(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := getWebhooksPath(repositoryName, "webhooks.json")
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &Webhook{Id: id, URL: target, Secret: secret, Events: events, Created: time.Now().UTC()}
+	if err = writeJSONFile(path, append(webhooks, webhook)); err != nil {
+		return nil, fmt.Errorf("unable to save webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook - Remove a webhook and its delivery log
+func DeleteWebhook(repositoryName, id string) error {
+	if err := checkWebhookScope(repositoryName); err != nil {
+		return err
+	}
+
+	webhooksMutex.Lock()
+	defer webhooksMutex.Unlock()
+
+	webhooks, err := readWebhooks(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]*Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if webhook.Id != id {
+			remaining = append(remaining, webhook)
+		}
+	}
+	if len(remaining) == len(webhooks) {
+		return NotFoundError
+	}
+
+	path, err := getWebhooksPath(repositoryName, "webhooks.json")
+	if err != nil {
+		return err
+	}
+
+	if err = writeJSONFile(path, remaining); err != nil {
+		return fmt.Errorf("unable to save webhooks: %w", err)
+	}
+
+	if path, err = getWebhookDeliveriesPath(repositoryName, id); err != nil {
+		return err
+	}
+
+	if err = removeFile(path); err != nil {
+		return fmt.Errorf("unable to delete webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries - Latest deliveries of a webhook, newest first
+func ListWebhookDeliveries(repositoryName, id string) ([]*WebhookDelivery, error) {
+	if _, err := GetWebhook(repositoryName, id); err != nil {
+		return nil, err
+	}
+
+	webhooksMutex.Lock()
+	defer webhooksMutex.Unlock()
+
+	path, err := getWebhookDeliveriesPath(repositoryName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []*WebhookDelivery
+	if _, err := readJSONFile(path, &deliveries); err != nil {
+		return nil, fmt.Errorf("unable to read webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RecordWebhookDelivery - Add a delivery attempt to the log of a webhook, as long as it still exists
+func RecordWebhookDelivery(repositoryName, id string, delivery *WebhookDelivery) error {
+	webhooksMutex.Lock()
+	defer webhooksMutex.Unlock()
+
+	// webhooks vanish with their repository, the log must not bring its folder back
+	webhooks, err := readWebhooks(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, webhook := range webhooks {
+		found = found || webhook.Id == id
+	}
+	if !found {
+		return NotFoundError
+	}
+
+	path, err := getWebhookDeliveriesPath(repositoryName, id)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []*WebhookDelivery
+	if _, err := readJSONFile(path, &deliveries); err != nil {
+		return fmt.Errorf("unable to read webhook deliveries: %w", err)
+	}
+
+	deliveries = append([]*WebhookDelivery{delivery}, deliveries...)
+	if len(deliveries) > maxWebhookDeliveries {
+		deliveries = deliveries[:maxWebhookDeliveries]
+	}
+
+	return writeJSONFile(path, deliveries)
+}
+
+// checkWebhookScope - Make sure webhooks of a repository are only kept in an actual repository
+func checkWebhookScope(repositoryName string) error {
+	if repositoryName == "" {
+		return nil
+	}
+
+	if _, err := openRepositoryNoSearch(repositoryName); err != nil {
+		return handleGitError(err, "unable to open repository")
+	}
+	return nil
+}
+
+func readWebhooks(repositoryName string) ([]*Webhook, error) {
+	path, err := getWebhooksPath(repositoryName, "webhooks.json")
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []*Webhook{}
+	if _, err := readJSONFile(path, &webhooks); err != nil {
+		return nil, fmt.Errorf("unable to read webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// getWebhooksPath - Path of webhook files, global ones live in the data directory
+func getWebhooksPath(repositoryName string, elem ...string) (string, error) {
+	if repositoryName == "" {
+		return filepath.Join(append([]string{GDataDirectory}, elem...)...), nil
+	}
+
+	name, err := ParseRepositoryName(repositoryName)
+	if err != nil {
+		return "", err
+	}
+	return name.dataPath(elem...), nil
+}
+
+func getWebhookDeliveriesPath(repositoryName, id string) (string, error) {
+	return getWebhooksPath(repositoryName, "webhook-deliveries", id+".json")
+}
+
+// randomId - Random identifier of 16 hexadecimal characters
+func randomId() (string, error) {
+	data := make([]byte, 8)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("unable to generate id: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
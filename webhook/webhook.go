@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"com/gitlab/gituim/repository"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// maxAttempts - Deliveries are retried until they succeed or this many attempts failed
+const maxAttempts = 5
+
+var (
+	// GAllowPrivateDestinations - Let webhooks call loopback, private and link-local addresses, which
+	// repository administrators could otherwise use to reach services of the host network
+	GAllowPrivateDestinations = os.Getenv("GITUIM_WEBHOOK_ALLOW_PRIVATE") == "true"
+
+	// firstRetryDelay - Wait before the first retry, doubled after every failed attempt
+	firstRetryDelay = 10 * time.Second
+)
+
+// sharedAddressSpace - Carrier-grade NAT range, where some clouds serve instance metadata
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		// no proxy, it would be the only destination the dialer gets to check
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkDestination}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        16,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	// redirects could lead anywhere, the response is taken as it is
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+type Payload struct {
+	Event      repository.EventType `json:"event"`
+	Delivery   string               `json:"delivery"`
+	Hook       string               `json:"hook"`
+	Repository string               `json:"repository"`
+	Previous   string               `json:"previous,omitempty"`
+	Updates    []*UpdatePayload     `json:"updates,omitempty"`
+	Timestamp  time.Time            `json:"timestamp"`
+}
+
+// UpdatePayload - Move of a reference, created and deleted references have a zero oid like in git hooks
+type UpdatePayload struct {
+	Ref    string `json:"ref"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Start - Deliver the events published by the repository package to the matching webhooks
+func Start() {
+	repository.Subscribe(func(event repository.Event) {
+		go dispatch(event, time.Now().UTC())
+	})
+}
+
+func dispatch(event repository.Event, timestamp time.Time) {
+	// the webhooks of a repository do not exist before it is created nor after it is deleted,
+	// moves reaching them under the new name
+	scopes := []string{""}
+	if event.Type != repository.RepositoryCreated && event.Type != repository.RepositoryDeleted {
+		scopes = append(scopes, event.Repository)
+	}
+
+	for _, scope := range scopes {
+		webhooks, err := repository.ListWebhooks(scope)
+		if err != nil {
+			log.Printf("unable to list webhooks of %q: %v", scope, err)
+			continue
+		}
+
+		for _, webhook := range webhooks {
+			if webhook.Wants(event.Type) {
+				go deliver(scope, webhook, event, timestamp)
+			}
+		}
+	}
+}
+
+// deliver - Post an event to a webhook, retrying with exponential backoff, and log every attempt
+func deliver(scope string, webhook *repository.Webhook, event repository.Event, timestamp time.Time) {
+	id, err := newDeliveryId()
+	if err != nil {
+		log.Printf("unable to deliver %s to webhook %s: %v", event.Type, webhook.Id, err)
+		return
+	}
+
+	body, err := json.Marshal(buildPayload(id, webhook, event, timestamp))
+	if err != nil {
+		log.Printf("unable to deliver %s to webhook %s: %v", event.Type, webhook.Id, err)
+		return
+	}
+
+	delay := firstRetryDelay
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		start := time.Now()
+		statusCode, err := send(webhook, event.Type, id, body)
+
+		delivery := &repository.WebhookDelivery{
+			Id:         id,
+			Event:      event.Type,
+			Repository: event.Repository,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Duration:   time.Since(start),
+			Delivered:  start.UTC(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		if recordErr := repository.RecordWebhookDelivery(scope, webhook.Id, delivery); recordErr != nil {
+			log.Printf("unable to record delivery %s of webhook %s: %v", id, webhook.Id, recordErr)
+		}
+
+		if err == nil {
+			return
+		}
+
+		log.Printf("delivery %s of %s to webhook %s failed, attempt %d: %v", id, event.Type, webhook.Id, attempt, err)
+		if attempt < maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// send - Post a payload, any status but 2xx is an error
+func send(webhook *repository.Webhook, event repository.EventType, id string, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "gituim-webhook")
+	request.Header.Set("X-Gituim-Event", string(event))
+	request.Header.Set("X-Gituim-Delivery", id)
+	if webhook.Secret != "" {
+		request.Header.Set("X-Gituim-Signature-256", Sign(webhook.Secret, body))
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// checkDestination - Refuse connections to addresses of the host network, checked once names are
+// resolved so that no DNS record can point a webhook there
+func checkDestination(_, address string, _ syscall.RawConn) error {
+	if GAllowPrivateDestinations {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("webhook destination %s is not a public address", host)
+	}
+	return nil
+}
+
+// Sign - Signature of a payload sent as X-Gituim-Signature-256, the hex HMAC SHA-256 of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func buildPayload(id string, webhook *repository.Webhook, event repository.Event, timestamp time.Time) *Payload {
+	payload := &Payload{
+		Event:      event.Type,
+		Delivery:   id,
+		Hook:       webhook.Id,
+		Repository: event.Repository,
+		Previous:   event.Previous,
+		Timestamp:  timestamp,
+	}
+
+	for _, update := range event.Updates {
+		payload.Updates = append(payload.Updates, &UpdatePayload{
+			Ref:    update.Name,
+			Before: oidString(update.Old),
+			After:  oidString(update.New),
+		})
+	}
+	return payload
+}
+
+func oidString(oid *git.Oid) string {
+	if oid == nil {
+		return (&git.Oid{}).String()
+	}
+	return oid.String()
+}
+
+func newDeliveryId() (string, error) {
+	data := make([]byte, 8)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("unable to generate delivery id: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
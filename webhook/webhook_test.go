@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"com/gitlab/gituim/repository"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// TestMain - Keep webhooks and their deliveries in a temporary storage root
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "gituim-webhook-")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repository.GRepositoryPrefix = root
+	repository.GDataDirectory = filepath.Join(root, ".gituim")
+	firstRetryDelay = time.Millisecond
+
+	code := m.Run()
+	os.RemoveAll(root)
+	os.Exit(code)
+}
+
+// allowPrivateDestinations - Let the test reach the loopback servers of httptest
+func allowPrivateDestinations(t *testing.T) {
+	previous := GAllowPrivateDestinations
+	GAllowPrivateDestinations = true
+	t.Cleanup(func() { GAllowPrivateDestinations = previous })
+}
+
+func TestSign(t *testing.T) {
+	expected := "sha256=d33f57c02e2f2777a742ec3001a3217deee926fbe61210a4caea0085f4f073b3"
+	if signature := Sign("secret", []byte(`{"event":"pushed"}`)); signature != expected {
+		t.Errorf("expected %s, got %s", expected, signature)
+	}
+}
+
+func TestBuildPayload(t *testing.T) {
+	oid, err := git.NewOid("0123456789abcdef0123456789abcdef01234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	payload := buildPayload("delivery", &repository.Webhook{Id: "hook"}, repository.Event{
+		Type:       repository.ReferencesUpdated,
+		Repository: "repo",
+		Updates: []repository.ReferenceUpdate{
+			{Name: "refs/heads/created", New: oid},
+			{Name: "refs/heads/deleted", Old: oid},
+		},
+	}, timestamp)
+
+	zero := strings.Repeat("0", 40)
+	expected := []UpdatePayload{
+		{Ref: "refs/heads/created", Before: zero, After: oid.String()},
+		{Ref: "refs/heads/deleted", Before: oid.String(), After: zero},
+	}
+	if payload.Event != repository.ReferencesUpdated || payload.Delivery != "delivery" || payload.Hook != "hook" ||
+		payload.Repository != "repo" || !payload.Timestamp.Equal(timestamp) || len(payload.Updates) != len(expected) {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	for i, update := range payload.Updates {
+		if *update != expected[i] {
+			t.Errorf("expected update %+v, got %+v", expected[i], *update)
+		}
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	allowPrivateDestinations(t)
+
+	var calls atomic.Int32
+	var signature atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		signature.Store(r.Header.Get("X-Gituim-Signature-256"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook, err := repository.CreateWebhook("", server.URL, "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = repository.DeleteWebhook("", webhook.Id) })
+
+	deliver("", webhook, repository.Event{Type: repository.RepositoryCreated, Repository: "repo"}, time.Now())
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+	if signature, _ := signature.Load().(string); !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("expected a signature, got %q", signature)
+	}
+
+	deliveries, err := repository.ListWebhookDeliveries("", webhook.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 || deliveries[0].Attempt != 3 || deliveries[0].Error != "" || deliveries[1].StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestCheckDestination(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:4700::1111]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"100.100.100.200:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+
+	for _, test := range tests {
+		err := checkDestination("tcp", test.address, nil)
+		if test.allowed && err != nil {
+			t.Errorf("%s: expected to be allowed, got %v", test.address, err)
+		}
+		if !test.allowed && err == nil {
+			t.Errorf("%s: expected to be refused", test.address)
+		}
+	}
+}
+
+func TestSendRefusesPrivateDestinations(t *testing.T) {
+	var called atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer server.Close()
+
+	if _, err := send(&repository.Webhook{URL: server.URL}, repository.Pushed, "id", []byte("{}")); err == nil {
+		t.Error("expected the loopback destination to be refused")
+	}
+	if called.Load() {
+		t.Error("expected the server not to be called")
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	allowPrivateDestinations(t)
+
+	var followed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	status, err := send(&repository.Webhook{URL: redirect.URL}, repository.Pushed, "id", []byte("{}"))
+	if err == nil || status != http.StatusFound {
+		t.Errorf("expected the redirect to fail the delivery, got %d: %v", status, err)
+	}
+	if followed.Load() {
+		t.Error("expected the redirect not to be followed")
+	}
+}
+
+func TestDispatchMovedToRepositoryWebhooks(t *testing.T) {
+	allowPrivateDestinations(t)
+
+	received := make(chan Payload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			received <- payload
+		}
+	}))
+	defer server.Close()
+
+	if _, err := repository.CreateRepository("hooked", ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repository.CreateWebhook("hooked", server.URL, "", []repository.EventType{repository.RepositoryCreated})
+	if !errors.Is(err, repository.InvalidArgumentError) {
+		t.Errorf("expected lifecycle events the repository never sees to be refused, got %v", err)
+	}
+	if _, err = repository.CreateWebhook("hooked", server.URL, "", []repository.EventType{repository.RepositoryMoved}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatch(repository.Event{Type: repository.RepositoryMoved, Repository: "hooked", Previous: "old"}, time.Now())
+
+	select {
+	case payload := <-received:
+		if payload.Event != repository.RepositoryMoved || payload.Repository != "hooked" || payload.Previous != "old" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the repository's webhook to receive the move")
+	}
+}